@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"cinlim.bikraj.net/internal/data"
+	"cinlim.bikraj.net/internal/validator"
+)
+
+// maxBatchIDs caps how many movies one batch fetch can ask for.
+const maxBatchIDs = 100
+
+// batchGetMoviesHandler is the POST form of GET /v1/movie?ids=, for id
+// lists too long for a query string. embed=images stays in the query string.
+func (app *application) batchGetMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		IDs []int64 `json:"ids"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	images := app.readImagesEmbed(r.URL.Query(), v)
+	validateBatchIDs(v, input.IDs)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	app.writeMovieBatch(w, r, input.IDs, images)
+}
+
+// readIDs reads a comma separated list of movie ids.
+func (app *application) readIDs(csv []string, v *validator.Validator) []int64 {
+	ids := make([]int64, 0, len(csv))
+	for _, s := range csv {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			v.AddError("ids", "must be a comma separated list of integers")
+			return nil
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func validateBatchIDs(v *validator.Validator, ids []int64) {
+	v.Check(len(ids) > 0, "ids", "must contain at least one id")
+	v.Check(len(ids) <= maxBatchIDs, "ids", "must not contain more than "+strconv.Itoa(maxBatchIDs)+" ids")
+	for _, id := range ids {
+		if id < 1 {
+			v.AddError("ids", "must only contain positive integers")
+			break
+		}
+	}
+}
+
+// writeMovieBatch fetches ids in one query and responds with the movies in
+// the order asked for, repeats dropped, along with the ids that were not
+// found. With images set each movie carries its images too.
+func (app *application) writeMovieBatch(w http.ResponseWriter, r *http.Request, ids []int64, images bool) {
+	found, err := app.models.Movies.GetMany(ids)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	w.Header().Add("Vary", "Accept-Language")
+	err = app.models.Titles.Localize(found, acceptLanguages(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if images {
+		err = app.embedImages(found)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	byID := make(map[int64]*data.Movie, len(found))
+	for _, movie := range found {
+		byID[movie.Id] = movie
+	}
+	movies := []*data.Movie{}
+	missing := []int64{}
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if movie, ok := byID[id]; ok {
+			movies = append(movies, movie)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	app.setRuntimeFormat(r, movies...)
+	err = app.writeJSON(w, http.StatusOK, envelope{"Movie": movies, "missing": missing}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
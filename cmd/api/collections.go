@@ -0,0 +1,301 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"cinlim.bikraj.net/internal/data"
+	"cinlim.bikraj.net/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+func (app *application) createCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	collection := &data.Collection{
+		Name:        input.Name,
+		Description: input.Description,
+	}
+	v := validator.New()
+	if data.ValidateCollection(v, collection); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Collection.Insert(collection)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/collections/%d", collection.ID))
+	err = app.writeJSON(w, http.StatusCreated, envelope{"collection": collection}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	collection, err := app.models.Collection.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"collection": collection}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listCollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string
+		data.Filter
+	}
+	v := validator.New()
+
+	qs := r.URL.Query()
+	input.Name = app.readString(qs, "name", "")
+	input.Filter.Page = app.readInt(qs, "page", 1, v)
+	input.Filter.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filter.Sort = app.readString(qs, "sort", "name")
+	input.Filter.SortSafeList = []string{"id", "name", "-id", "-name"}
+
+	if data.ValidateFilters(v, input.Filter); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	collections, metadata, err := app.models.Collection.GetAll(input.Name, input.Filter)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"metadata": metadata, "collections": collections}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	collection, err := app.models.Collection.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if input.Name != nil {
+		collection.Name = *input.Name
+	}
+	if input.Description != nil {
+		collection.Description = *input.Description
+	}
+
+	v := validator.New()
+	if data.ValidateCollection(v, collection); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Collection.Update(collection)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"collection": collection}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	err = app.models.Collection.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "Collection Removed Successfully"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// addCollectionMovieHandler appends a movie to the end of a collection.
+func (app *application) addCollectionMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		MovieID int64 `json:"movie_id"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	v.Check(input.MovieID > 0, "movie_id", "must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	exists, err := app.movieExists(input.MovieID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !exists {
+		v.AddError("movie_id", "no movie exists with this id")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Collection.AddMovie(id, input.MovieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateCollectionMovie):
+			v.AddError("movie_id", "movie is already in this collection")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.writeCollection(w, r, id, http.StatusCreated)
+}
+
+// reorderCollectionHandler moves the listed movies to the front of the
+// collection in the order given.
+func (app *application) reorderCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		MovieIDs []int64 `json:"movie_ids"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	v.Check(len(input.MovieIDs) > 0, "movie_ids", "must contain at least one movie")
+	v.Check(len(input.MovieIDs) <= 1000, "movie_ids", "must not contain more than 1000 movies")
+	v.Check(validator.Unique(input.MovieIDs), "movie_ids", "must be unique")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Collection.Reorder(id, input.MovieIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.writeCollection(w, r, id, http.StatusOK)
+}
+
+func (app *application) removeCollectionMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	movieID, err := strconv.ParseInt(httprouter.ParamsFromContext(r.Context()).ByName("movie_id"), 10, 64)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	err = app.models.Collection.RemoveMovie(id, movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.writeCollection(w, r, id, http.StatusOK)
+}
+
+// writeCollection responds with the current state of a collection after
+// its membership has changed.
+func (app *application) writeCollection(w http.ResponseWriter, r *http.Request, id int64, status int) {
+	collection, err := app.models.Collection.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, status, envelope{"collection": collection}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+
+	"cinlim.bikraj.net/internal/data"
+)
+
+// movieETag is the entity tag for a single movie. The version covers every
+// edit; the rating aggregates are refreshed on the row without bumping the
+// version, so they are part of the tag too.
+func movieETag(movie *data.Movie) string {
+	return fmt.Sprintf(`"%d-%d-%g"`, movie.Version, movie.RatingCount, movie.AverageRating)
+}
+
+// hashETag tags a response by hashing its JSON encoding. It is used where the
+// body is built from several rows, such as a page of movies.
+func hashETag(v any) (string, error) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	h := fnv.New64a()
+	h.Write(js)
+	return fmt.Sprintf(`"%016x"`, h.Sum64()), nil
+}
+
+// variantETag tags one representation of a movie, such as a sparse fieldset
+// or an embed, as "<movie tag>:<variant>". Caches see each variant as its own
+// entity, while checkIfMatch ignores the variant so any of them can be sent
+// back on a PATCH or DELETE.
+func variantETag(etag, variant string) string {
+	return strings.TrimSuffix(etag, `"`) + ":" + strings.Trim(variant, `"`) + `"`
+}
+
+// stripETagVariants drops the variant from each tag in an If-Match value,
+// leaving the movie tag it was built from.
+func stripETagVariants(header string) string {
+	tags := strings.Split(header, ",")
+	for i, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if j := strings.IndexByte(tag, ':'); j >= 0 {
+			tag = tag[:j] + `"`
+		}
+		tags[i] = tag
+	}
+	return strings.Join(tags, ",")
+}
+
+// etagListMatches reports whether etag appears in an If-Match or
+// If-None-Match header value. Weak comparison ignores the W/ prefix.
+func etagListMatches(header, etag string, weak bool) bool {
+	if weak {
+		etag = strings.TrimPrefix(etag, "W/")
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		} else if strings.HasPrefix(candidate, "W/") {
+			continue
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified sets the ETag header and, when the request's If-None-Match
+// already holds it, answers 304 Not Modified. The caller should return
+// without writing a body when it reports true.
+func (app *application) notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	inm := r.Header.Get("If-None-Match")
+	if inm == "" || !etagListMatches(inm, etag, true) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// checkIfMatch enforces If-Match on a write against the current ETag of the
+// resource. Any variant of that tag from variantETag matches too. It writes a
+// 412 when the tag does not match, or a 428 when the header is missing and
+// the server requires preconditions, and reports whether the handler may
+// carry on.
+func (app *application) checkIfMatch(w http.ResponseWriter, r *http.Request, etag string) bool {
+	im := r.Header.Get("If-Match")
+	switch {
+	case im == "" && app.config.preconditions.required:
+		app.preconditionRequiredResponse(w, r)
+		return false
+	case im != "" && !etagListMatches(stripETagVariants(im), etag, false):
+		app.preconditionFailedResponse(w, r)
+		return false
+	}
+	return true
+}
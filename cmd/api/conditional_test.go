@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cinlim.bikraj.net/internal/data"
+)
+
+func TestVariantETag(t *testing.T) {
+	tests := []struct {
+		etag, variant, want string
+	}{
+		{`"3-0-0"`, "iso8601", `"3-0-0:iso8601"`},
+		{`"3-0-0"`, `"00ff00ff00ff00ff"`, `"3-0-0:00ff00ff00ff00ff"`},
+		{`W/"3-0-0"`, "hm", `W/"3-0-0:hm"`},
+	}
+	for _, tt := range tests {
+		if got := variantETag(tt.etag, tt.variant); got != tt.want {
+			t.Errorf("variantETag(%s, %s) = %s, want %s", tt.etag, tt.variant, got, tt.want)
+		}
+	}
+}
+
+func TestRuntimeETag(t *testing.T) {
+	if got := runtimeETag(`"3-0-0"`, "mins"); got != `"3-0-0"` {
+		t.Errorf(`runtimeETag for the default format = %s, want "3-0-0"`, got)
+	}
+	if got := runtimeETag(`"3-0-0"`, "hm"); got != `"3-0-0:hm"` {
+		t.Errorf(`runtimeETag for hm = %s, want "3-0-0:hm"`, got)
+	}
+}
+
+func TestStripETagVariants(t *testing.T) {
+	tests := []struct {
+		header, want string
+	}{
+		{`"3-0-0"`, `"3-0-0"`},
+		{`"3-0-0:hm"`, `"3-0-0"`},
+		{`"3-0-0:hm", "4-1-5:0123456789abcdef"`, `"3-0-0","4-1-5"`},
+		{`W/"3-0-0:hm"`, `W/"3-0-0"`},
+		{`*`, `*`},
+	}
+	for _, tt := range tests {
+		if got := stripETagVariants(tt.header); got != tt.want {
+			t.Errorf("stripETagVariants(%s) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestETagListMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		etag   string
+		weak   bool
+		want   bool
+	}{
+		{name: "same tag", header: `"a"`, etag: `"a"`, want: true},
+		{name: "different tag", header: `"b"`, etag: `"a"`},
+		{name: "tag in a list", header: `"b", "a" ,"c"`, etag: `"a"`, want: true},
+		{name: "wildcard", header: `*`, etag: `"a"`, want: true},
+		{name: "wildcard in a list", header: `"b", *`, etag: `"a"`, want: true},
+		{name: "weak tag under strong comparison", header: `W/"a"`, etag: `"a"`},
+		{name: "weak tag under weak comparison", header: `W/"a"`, etag: `"a"`, weak: true, want: true},
+		{name: "strong tag against a weak one", header: `"a"`, etag: `W/"a"`, weak: true, want: true},
+		{name: "unquoted tag", header: `a`, etag: `"a"`},
+		{name: "empty header", header: ``, etag: `"a"`},
+	}
+	for _, tt := range tests {
+		if got := etagListMatches(tt.header, tt.etag, tt.weak); got != tt.want {
+			t.Errorf("%s: etagListMatches(%s, %s, %t) = %t, want %t", tt.name, tt.header, tt.etag, tt.weak, got, tt.want)
+		}
+	}
+}
+
+func TestCheckIfMatch(t *testing.T) {
+	etag := movieETag(&data.Movie{Version: 3, RatingCount: 2, AverageRating: 4.5})
+	tests := []struct {
+		name       string
+		ifMatch    string
+		required   bool
+		wantOK     bool
+		wantStatus int
+	}{
+		{name: "no header", wantOK: true},
+		{name: "no header when required", required: true, wantStatus: http.StatusPreconditionRequired},
+		{name: "current tag", ifMatch: etag, wantOK: true},
+		{name: "variant of the current tag", ifMatch: variantETag(etag, "hm"), required: true, wantOK: true},
+		{name: "wildcard", ifMatch: "*", wantOK: true},
+		{name: "stale tag", ifMatch: `"2-2-4.5"`, wantStatus: http.StatusPreconditionFailed},
+		{name: "weak tag", ifMatch: "W/" + etag, wantStatus: http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &application{}
+			app.config.preconditions.required = tt.required
+
+			r := httptest.NewRequest(http.MethodPatch, "/v1/movie/1", nil)
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+
+			if got := app.checkIfMatch(w, r, etag); got != tt.wantOK {
+				t.Fatalf("checkIfMatch = %t, want %t", got, tt.wantOK)
+			}
+			if !tt.wantOK && w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	tests := []struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		{"", false},
+		{`"a"`, true},
+		{`W/"a"`, true},
+		{`"b", "a"`, true},
+		{`*`, true},
+		{`"b"`, false},
+	}
+	for _, tt := range tests {
+		app := &application{}
+		r := httptest.NewRequest(http.MethodGet, "/v1/movie/1", nil)
+		if tt.ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", tt.ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+
+		got := app.notModified(w, r, `"a"`)
+		if got != tt.want {
+			t.Errorf("notModified with If-None-Match %s = %t, want %t", tt.ifNoneMatch, got, tt.want)
+		}
+		if tag := w.Header().Get("ETag"); tag != `"a"` {
+			t.Errorf("ETag header = %s, want \"a\"", tag)
+		}
+		if got && w.Code != http.StatusNotModified {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusNotModified)
+		}
+	}
+}
@@ -9,7 +9,10 @@ import (
 
 type contextKey string
 
-const userContextKey = contextKey("user")
+const (
+	userContextKey          = contextKey("user")
+	runtimeFormatContextKey = contextKey("runtime_format")
+)
 
 func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
 	ctx := context.WithValue(r.Context(), userContextKey, user)
@@ -23,3 +26,18 @@ func (app *application) contextGetUser(r *http.Request) *data.User {
 	}
 	return user
 }
+
+func (app *application) contextSetRuntimeFormat(r *http.Request, format string) *http.Request {
+	ctx := context.WithValue(r.Context(), runtimeFormatContextKey, format)
+	return r.WithContext(ctx)
+}
+
+// contextGetRuntimeFormat returns the format runtimeFormat stored, or "mins"
+// for requests that did not pass through it.
+func (app *application) contextGetRuntimeFormat(r *http.Request) string {
+	format, ok := r.Context().Value(runtimeFormatContextKey).(string)
+	if !ok {
+		return "mins"
+	}
+	return format
+}
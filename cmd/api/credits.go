@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"cinlim.bikraj.net/internal/data"
+	"cinlim.bikraj.net/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+func (app *application) listCreditsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	credits, err := app.models.Credits.GetForMovie(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"credits": credits}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createCreditHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		PersonID  int64  `json:"person_id"`
+		Role      string `json:"role"`
+		Character string `json:"character"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	credit := &data.Credit{
+		MovieID:   id,
+		PersonID:  input.PersonID,
+		Role:      input.Role,
+		Character: input.Character,
+	}
+	v := validator.New()
+	if data.ValidateCredit(v, credit); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	exists, err := app.movieExists(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !exists {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Credits.Insert(credit)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			v.AddError("person_id", "no person exists with this id")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateCredit):
+			v.AddError("person_id", "person already has this credit on the movie")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusCreated, envelope{"credit": credit}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteCreditHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	creditID, err := strconv.ParseInt(httprouter.ParamsFromContext(r.Context()).ByName("credit_id"), 10, 64)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	err = app.models.Credits.Delete(id, creditID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "Credit Removed Successfully"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
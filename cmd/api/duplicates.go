@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+
+	"cinlim.bikraj.net/internal/data"
+	"cinlim.bikraj.net/internal/validator"
+)
+
+// listDuplicatesHandler reports clusters of movies that look like copies of
+// each other, for an editor to merge or delete.
+func (app *application) listDuplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filter
+	}
+	v := validator.New()
+
+	qs := r.URL.Query()
+	input.Filter.Page = app.readInt(qs, "page", 1, v)
+	input.Filter.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filter.Sort = "id"
+	input.Filter.SortSafeList = []string{"id"}
+
+	if data.ValidateFilters(v, input.Filter); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	clusters, metadata, err := app.models.Movies.GetDuplicateClusters(input.Filter)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"metadata": metadata, "clusters": clusters}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -3,6 +3,9 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"strings"
+
+	"cinlim.bikraj.net/internal/data"
 )
 
 func (app *application) logError(r *http.Request, err error) {
@@ -15,7 +18,7 @@ func (app *application) logError(r *http.Request, err error) {
 func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message any) {
 	env := envelope{"error": message}
 
-	err := app.writeJSON(w, http.StatusInternalServerError, env, nil)
+	err := app.writeJSON(w, status, env, nil)
 
 	if err != nil {
 		app.logError(r, err)
@@ -58,6 +61,11 @@ func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http
 	message := "Rate Limit Exceeded"
 	app.errorResponse(w, r, http.StatusTooManyRequests, message)
 }
+func (app *application) serverBusyResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", "5")
+	message := "the server is too busy to handle this request, please try again shortly"
+	app.errorResponse(w, r, http.StatusServiceUnavailable, message)
+}
 func (app *application) invalidCredentialResponse(w http.ResponseWriter, r *http.Request) {
 	message := "The email or the password doesnot match"
 	app.errorResponse(w, r, http.StatusUnauthorized, message)
@@ -82,3 +90,32 @@ func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Requ
 	message := "your user account doesnot have the permission to access this resource"
 	app.errorResponse(w, r, http.StatusForbidden, message)
 }
+
+func (app *application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request, supported ...string) {
+	message := fmt.Sprintf("the request body must be one of: %s", strings.Join(supported, ", "))
+	app.errorResponse(w, r, http.StatusUnsupportedMediaType, message)
+}
+
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the resource has changed since it was last fetched, please fetch it again"
+	app.errorResponse(w, r, http.StatusPreconditionFailed, message)
+}
+
+func (app *application) preconditionRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this request must be made conditional with an If-Match header"
+	app.errorResponse(w, r, http.StatusPreconditionRequired, message)
+}
+
+// duplicateMovieResponse refuses a create that looks like a movie we already
+// have. The check is advisory: it runs before the insert without a lock, so
+// two similar movies created at the same moment can both get through. Clients
+// must not rely on a missing 409 to mean no duplicate exists; the duplicates
+// report catches those that slip by.
+func (app *application) duplicateMovieResponse(w http.ResponseWriter, r *http.Request, candidates []*data.DuplicateCandidate) {
+	message := "a similar movie already exists, resend with force=true to create it anyway"
+	err := app.writeJSON(w, http.StatusConflict, envelope{"error": message, "candidates": candidates}, nil)
+	if err != nil {
+		app.logError(r, err)
+		w.WriteHeader(http.StatusConflict)
+	}
+}
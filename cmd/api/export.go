@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cinlim.bikraj.net/internal/data"
+	"cinlim.bikraj.net/internal/validator"
+)
+
+// exportFlushEvery is how many movies are written between flushes.
+const exportFlushEvery = 100
+
+func (app *application) exportMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.MovieQuery
+		data.Filter
+		Format string
+	}
+	v := validator.New()
+
+	qs := r.URL.Query()
+	input.MovieQuery = app.readMovieQuery(qs, v)
+
+	input.Filter.Sort = app.readString(qs, "sort", "id")
+	input.Filter.SortSafeList = movieSortSafeList
+	input.Format = app.readString(qs, "format", exportFormatFromAccept(r.Header.Get("Accept")))
+	runtimeFormat := app.contextGetRuntimeFormat(r)
+
+	v.Check(validator.In(input.Filter.Sort, input.Filter.SortSafeList...), "sort", "Invalid sort value")
+	v.Check(strings.TrimPrefix(input.Filter.Sort, "-") != "relevance" || input.Title != "", "sort", "relevance sort requires a title search")
+	v.Check(validator.In(input.Format, "csv", "ndjson"), "format", "must be csv or ndjson")
+	data.ValidateMovieQuery(v, input.MovieQuery)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// An export can run far longer than the server's WriteTimeout.
+	rc := http.NewResponseController(w)
+	err := rc.SetWriteDeadline(time.Time{})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var contentType, filename string
+	var begin func() error
+	var write func(*data.Movie) error
+	var flush func() error
+	switch input.Format {
+	case "csv":
+		contentType, filename = "text/csv", "movies.csv"
+		cw := csv.NewWriter(w)
+		begin = func() error {
+			return cw.Write([]string{"id", "created_at", "title", "original_language", "year", "runtime", "genres", "version"})
+		}
+		write = func(movie *data.Movie) error {
+			return cw.Write([]string{
+				strconv.FormatInt(movie.Id, 10),
+				movie.CreatedAt.Format(time.RFC3339),
+				movie.Title,
+				movie.OriginalLanguage,
+				strconv.FormatInt(int64(movie.Year), 10),
+				fmt.Sprint(movie.Runtime.Format(runtimeFormat)),
+				strings.Join(movie.Genres, "|"),
+				strconv.FormatInt(int64(movie.Version), 10),
+			})
+		}
+		flush = func() error {
+			cw.Flush()
+			if err := cw.Error(); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+	default:
+		contentType, filename = "application/x-ndjson", "movies.ndjson"
+		enc := json.NewEncoder(w)
+		begin = func() error { return nil }
+		write = func(movie *data.Movie) error {
+			movie.Rank = 0
+			movie.RuntimeFormat = runtimeFormat
+			return enc.Encode(movie)
+		}
+		flush = rc.Flush
+	}
+
+	// The status line waits for the first batch of rows, so a query that
+	// fails before then still gets a proper error response rather than an
+	// empty attachment.
+	started := false
+	start := func() error {
+		if started {
+			return nil
+		}
+		started = true
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		w.WriteHeader(http.StatusOK)
+		return begin()
+	}
+
+	written := 0
+	err = app.models.Movies.Export(input.MovieQuery, input.Filter, func(movie *data.Movie) error {
+		err := start()
+		if err == nil {
+			err = write(movie)
+		}
+		if err != nil {
+			return err
+		}
+		written++
+		if written%exportFlushEvery == 0 {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil && !started {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if err == nil {
+		err = start()
+	}
+	if err == nil {
+		err = flush()
+	}
+	// The status line has already gone out, so all that is left is to log.
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// exportFormatFromAccept picks an export format from the Accept header,
+// defaulting to NDJSON.
+func exportFormatFromAccept(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/csv":
+			return "csv"
+		case "application/x-ndjson", "application/ndjson", "application/jsonl":
+			return "ndjson"
+		}
+	}
+	return "ndjson"
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"cinlim.bikraj.net/internal/data"
+	"cinlim.bikraj.net/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// showMovieByExternalIDHandler finds a movie by one of its external ids and
+// answers exactly as GET /v1/movie/:id would, query parameters included.
+func (app *application) showMovieByExternalIDHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := app.models.External.GetMovieID(params.ByName("namespace"), params.ByName("value"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	w.Header().Set("Content-Location", fmt.Sprintf("/v1/movie/%d", id))
+	params = httprouter.Params{{Key: "id", Value: strconv.FormatInt(id, 10)}}
+	ctx := context.WithValue(r.Context(), httprouter.ParamsKey, params)
+	app.showMovieHandler(w, r.WithContext(ctx))
+}
+
+func (app *application) listExternalIDsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	exists, err := app.movieExists(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !exists {
+		app.notFoundResponse(w, r)
+		return
+	}
+	ids, err := app.models.External.GetForMovie(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"external_ids": ids}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// putExternalIDHandler sets the movie's id in one namespace, replacing any
+// value it already had there.
+func (app *application) putExternalIDHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	namespace := httprouter.ParamsFromContext(r.Context()).ByName("namespace")
+	var input struct {
+		Value string `json:"value"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	if data.ValidateExternalID(v, namespace, input.Value); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	exists, err := app.movieExists(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !exists {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.External.Put(id, namespace, input.Value)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateExternalID):
+			v.AddError("external_ids", fmt.Sprintf("%s:%s already belongs to another movie", namespace, input.Value))
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.listExternalIDsHandler(w, r)
+}
+
+func (app *application) deleteExternalIDHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	namespace := httprouter.ParamsFromContext(r.Context()).ByName("namespace")
+	// The ids of a movie in the trash are kept for Restore and cannot be
+	// removed until it is back.
+	exists, err := app.movieExists(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !exists {
+		app.notFoundResponse(w, r)
+		return
+	}
+	err = app.models.External.Delete(id, namespace)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "External Id Removed Successfully"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// upsertMovie overwrites the movie with id using the fields of input, which
+// createMovieHandler has already validated, and adds input's external ids.
+// If-Match is checked against the movie as it is now, as on PATCH, so an
+// upsert fails with 412 only when the movie it matched has changed.
+func (app *application) upsertMovie(w http.ResponseWriter, r *http.Request, id int64, input *data.Movie) {
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if !app.checkIfMatch(w, r, movieETag(movie)) {
+		return
+	}
+	movie.Title = input.Title
+	movie.Year = input.Year
+	movie.Runtime = input.Runtime
+	movie.Genres = input.Genres
+	movie.OriginalLanguage = input.OriginalLanguage
+	movie.ExternalIDs = input.ExternalIDs
+
+	err = app.models.Movies.Update(movie, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateExternalID):
+			app.failedValidationResponse(w, r, map[string]string{"external_ids": err.Error()})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/movie/%d", movie.Id))
+	app.setRuntimeFormat(r, movie)
+	err = app.writeJSON(w, http.StatusOK, envelope{"Movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -9,7 +9,9 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
+	"cinlim.bikraj.net/internal/data"
 	"cinlim.bikraj.net/internal/validator"
 	"github.com/julienschmidt/httprouter"
 )
@@ -105,7 +107,7 @@ func (app *application) movieExists(id int64) (bool, error) {
 	query :=
 		`
   SELECT FROM movies 
-  WHERE id = $1
+  WHERE id = $1 AND deleted_at IS NULL
   `
 	result, err := app.models.Movies.DB.Exec(query, id)
 	if err != nil {
@@ -135,6 +137,54 @@ func (app *application) readCsv(qs url.Values, key string, defaultValue []string
 	}
 	return strings.Split(csv, ",")
 }
+
+// readFields reads a comma separated fields= list, recording an error for
+// any name that is not in allowed.
+func (app *application) readFields(qs url.Values, key string, allowed []string, v *validator.Validator) []string {
+	fields := app.readCsv(qs, key, []string{})
+	for _, field := range fields {
+		if !validator.In(field, allowed...) {
+			v.AddError(key, fmt.Sprintf("unknown field %q, must be one of %s", field, strings.Join(allowed, ", ")))
+			break
+		}
+	}
+	return fields
+}
+
+// pickFields keeps only the keys in fields from the JSON object src encodes
+// to. src is returned unchanged when fields is empty.
+func pickFields(src any, fields []string) (any, error) {
+	if len(fields) == 0 {
+		return src, nil
+	}
+	js, err := json.Marshal(src)
+	if err != nil {
+		return nil, err
+	}
+	var object map[string]json.RawMessage
+	err = json.Unmarshal(js, &object)
+	if err != nil {
+		return nil, err
+	}
+	picked := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if value, ok := object[field]; ok {
+			picked[field] = value
+		}
+	}
+	return picked, nil
+}
+
+// readGenres reads a comma separated genre filter, folding each spelling to
+// the form genre slugs are stored in.
+func (app *application) readGenres(qs url.Values, key string) []string {
+	genres := app.readCsv(qs, key, []string{})
+	for i, genre := range genres {
+		genres[i] = data.GenreKey(genre)
+	}
+	return genres
+}
+
 func (app *application) readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
 	s := qs.Get(key)
 	if s == "" {
@@ -147,6 +197,47 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	}
 	return i
 }
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return defaultValue
+	}
+	return b
+}
+func (app *application) readRuntime(qs url.Values, key string, v *validator.Validator) data.Runtime {
+	s := qs.Get(key)
+	if s == "" {
+		return 0
+	}
+	runtime, err := data.ParseRuntime(s)
+	if err != nil {
+		v.AddError(key, err.Error())
+		return 0
+	}
+	return runtime
+}
+
+// readTime accepts either an RFC 3339 timestamp or a plain 2006-01-02 date.
+func (app *application) readTime(qs url.Values, key string, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+	if s == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t, err = time.Parse(time.DateOnly, s)
+	}
+	if err != nil {
+		v.AddError(key, "must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+		return time.Time{}
+	}
+	return t
+}
 func (app *application) background(fn func()) {
 
 	app.wg.Add(1)
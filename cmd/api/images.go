@@ -0,0 +1,480 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"cinlim.bikraj.net/internal/blob"
+	"cinlim.bikraj.net/internal/data"
+	"cinlim.bikraj.net/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// thumbnailWidth is the width thumbnails are scaled down to.
+	thumbnailWidth = 320
+	// maxImagePixels guards against small files that decode to huge images.
+	// A decoded image takes up to four bytes a pixel, so this caps a
+	// thumbnail worker at about 100 MB.
+	maxImagePixels = 24_000_000
+)
+
+// imageExtensions lists the sniffed content types accepted for upload.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+func (app *application) uploadMovieImageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	exists, err := app.movieExists(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !exists {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// Leave room for the multipart framing and the kind field.
+	maxBytes := app.config.images.maxBytes
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+64*1024)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		app.unsupportedMediaTypeResponse(w, r, "multipart/form-data")
+		return
+	}
+
+	img := &data.MovieImage{MovieID: id, Kind: "poster"}
+	var content []byte
+	v := validator.New()
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			app.badRequestResponse(w, r, multipartError(err, maxBytes))
+			return
+		}
+		switch part.FormName() {
+		case "kind":
+			kind, err := io.ReadAll(io.LimitReader(part, 64))
+			if err != nil {
+				app.badRequestResponse(w, r, multipartError(err, maxBytes))
+				return
+			}
+			img.Kind = strings.TrimSpace(string(kind))
+		case "image":
+			content, err = io.ReadAll(io.LimitReader(part, maxBytes+1))
+			if err != nil {
+				app.badRequestResponse(w, r, multipartError(err, maxBytes))
+				return
+			}
+			v.Check(int64(len(content)) <= maxBytes, "image", fmt.Sprintf("must not be larger than %d bytes", maxBytes))
+		default:
+			app.badRequestResponse(w, r, fmt.Errorf("body contains unknown field %q", part.FormName()))
+			return
+		}
+	}
+	v.Check(len(content) > 0, "image", "must be provided")
+	data.ValidateMovieImage(v, img)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Trust the bytes rather than the client's Content-Type.
+	img.ContentType = http.DetectContentType(content)
+	ext, ok := imageExtensions[img.ContentType]
+	if !ok {
+		app.unsupportedMediaTypeResponse(w, r, "image/jpeg", "image/png", "image/gif")
+		return
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		v.AddError("image", "could not be decoded")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	if v.Check(config.Width*config.Height <= maxImagePixels, "image", "has too many pixels"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	img.Width, img.Height = config.Width, config.Height
+	img.Size = int64(len(content))
+
+	// Take a thumbnail slot before storing anything. Decoding is what costs
+	// memory, so when every slot is busy the upload is turned away rather
+	// than left queueing in the background.
+	select {
+	case app.thumbnails <- struct{}{}:
+	default:
+		app.serverBusyResponse(w, r)
+		return
+	}
+	thumbnailQueued := false
+	defer func() {
+		if !thumbnailQueued {
+			<-app.thumbnails
+		}
+	}()
+
+	name, err := randomName()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	img.BlobKey = fmt.Sprintf("movies/%d/%s%s", id, name, ext)
+	err = app.blobs.Put(r.Context(), img.BlobKey, bytes.NewReader(content))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.models.Images.Insert(img)
+	if err != nil {
+		app.blobs.Delete(context.Background(), img.BlobKey)
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	thumbnailQueued = true
+	app.background(func() {
+		defer func() { <-app.thumbnails }()
+		err := app.createThumbnail(img)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"image_id": strconv.FormatInt(img.ID, 10)})
+		}
+	})
+
+	app.imageURLs(img)
+	headers := make(http.Header)
+	headers.Set("Location", img.URL)
+	err = app.writeJSON(w, http.StatusCreated, envelope{"image": img}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// multipartError turns an error reading an upload into one fit for the
+// client.
+func multipartError(err error, maxBytes int64) error {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return fmt.Errorf("body must not be larger than %d bytes", maxBytes)
+	}
+	return fmt.Errorf("body is not valid multipart form data: %w", err)
+}
+
+func randomName() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// createThumbnail scales an uploaded image down and stores it next to the
+// original. PNG and GIF thumbnails stay PNG so transparency survives.
+func (app *application) createThumbnail(img *data.MovieImage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	rc, err := app.blobs.Open(ctx, img.BlobKey)
+	if err != nil {
+		return err
+	}
+	src, _, err := image.Decode(rc)
+	rc.Close()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	thumb := thumbnail(src, thumbnailWidth)
+	ext := path.Ext(img.BlobKey)
+	if img.ContentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 80})
+	} else {
+		ext = ".png"
+		err = png.Encode(&buf, thumb)
+	}
+	if err != nil {
+		return err
+	}
+
+	key := strings.TrimSuffix(img.BlobKey, path.Ext(img.BlobKey)) + "_thumb" + ext
+	err = app.blobs.Put(ctx, key, &buf)
+	if err != nil {
+		return err
+	}
+	err = app.models.Images.SetThumbnail(img.ID, key)
+	if err != nil {
+		// The image was deleted while the thumbnail was being made.
+		app.blobs.Delete(ctx, key)
+		if errors.Is(err, data.ErrNoRecordFound) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// thumbnail scales src down to width, keeping its aspect ratio, by averaging
+// the block of source pixels behind each thumbnail pixel. Images that are
+// already narrow enough are copied at their own size.
+func thumbnail(src image.Image, width int) image.Image {
+	b := src.Bounds()
+	width = min(width, b.Dx())
+	height := max(1, b.Dy()*width/b.Dx())
+	pixel := pixelReader(src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := b.Min.Y+y*b.Dy()/height, b.Min.Y+(y+1)*b.Dy()/height
+		for x := 0; x < width; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/width, b.Min.X+(x+1)*b.Dx()/width
+			var sr, sg, sb, sa, n uint32
+			for sy := y0; sy < max(y1, y0+1); sy++ {
+				for sx := x0; sx < max(x1, x0+1); sx++ {
+					cr, cg, cb, ca := pixel(sx, sy)
+					sr, sg, sb, sa = sr+cr, sg+cg, sb+cb, sa+ca
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = uint8(sr/n), uint8(sg/n), uint8(sb/n), uint8(sa/n)
+		}
+	}
+	return dst
+}
+
+// pixelReader returns a function giving the 8-bit, alpha-premultiplied colour
+// of src at x, y. The image types the decoders produce are read straight from
+// their pixel slices; anything else falls back to At.
+func pixelReader(src image.Image) func(x, y int) (r, g, b, a uint32) {
+	switch src := src.(type) {
+	case *image.YCbCr:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			yi, ci := src.YOffset(x, y), src.COffset(x, y)
+			r, g, b := color.YCbCrToRGB(src.Y[yi], src.Cb[ci], src.Cr[ci])
+			return uint32(r), uint32(g), uint32(b), 0xff
+		}
+	case *image.RGBA:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			p := src.Pix[src.PixOffset(x, y):]
+			return uint32(p[0]), uint32(p[1]), uint32(p[2]), uint32(p[3])
+		}
+	case *image.NRGBA:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			p := src.Pix[src.PixOffset(x, y):]
+			a := uint32(p[3])
+			return uint32(p[0]) * a / 0xff, uint32(p[1]) * a / 0xff, uint32(p[2]) * a / 0xff, a
+		}
+	case *image.Gray:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			v := uint32(src.Pix[src.PixOffset(x, y)])
+			return v, v, v, 0xff
+		}
+	case *image.Paletted:
+		rgba := make([]color.RGBA, len(src.Palette))
+		for i, c := range src.Palette {
+			rgba[i] = color.RGBAModel.Convert(c).(color.RGBA)
+		}
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			i := int(src.Pix[src.PixOffset(x, y)])
+			if i >= len(rgba) {
+				return 0, 0, 0, 0
+			}
+			c := rgba[i]
+			return uint32(c.R), uint32(c.G), uint32(c.B), uint32(c.A)
+		}
+	default:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			r, g, b, a := src.At(x, y).RGBA()
+			return r >> 8, g >> 8, b >> 8, a >> 8
+		}
+	}
+}
+
+// imageURLs fills in the URLs of images from their blob keys.
+func (app *application) imageURLs(images ...*data.MovieImage) {
+	for _, img := range images {
+		img.URL = app.blobs.URL(img.BlobKey)
+		if img.ThumbnailKey != "" {
+			img.ThumbnailURL = app.blobs.URL(img.ThumbnailKey)
+		}
+	}
+}
+
+// readImagesEmbed reads embed= on the movie list and batch fetch, where
+// images are the only relation that can be embedded.
+func (app *application) readImagesEmbed(qs url.Values, v *validator.Validator) bool {
+	embed := app.readCsv(qs, "embed", []string{})
+	for _, relation := range embed {
+		v.Check(relation == "images", "embed", "must only contain images")
+	}
+	return validator.In("images", embed...)
+}
+
+// embedImages fills in the images of each movie, with their URLs, using one
+// query for all of them.
+func (app *application) embedImages(movies []*data.Movie) error {
+	ids := make([]int64, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.Id
+	}
+	images, err := app.models.Images.GetForMovies(ids)
+	if err != nil {
+		return err
+	}
+	for _, movie := range movies {
+		movie.Images = images[movie.Id]
+		if movie.Images == nil {
+			movie.Images = []*data.MovieImage{}
+		}
+		app.imageURLs(movie.Images...)
+	}
+	return nil
+}
+
+func (app *application) listMovieImagesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	exists, err := app.movieExists(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !exists {
+		app.notFoundResponse(w, r)
+		return
+	}
+	images, err := app.models.Images.GetForMovie(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.imageURLs(images...)
+	err = app.writeJSON(w, http.StatusOK, envelope{"images": images}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieImageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	imageID, err := strconv.ParseInt(httprouter.ParamsFromContext(r.Context()).ByName("image_id"), 10, 64)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	img, err := app.models.Images.Delete(id, imageID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.deleteImageBlobs(img)
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "Image Removed Successfully"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteImageBlobs removes an image's files once its row is gone. Failures
+// only leave orphaned files behind, so they are logged rather than returned.
+func (app *application) deleteImageBlobs(images ...*data.MovieImage) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	for _, img := range images {
+		for _, key := range []string{img.BlobKey, img.ThumbnailKey} {
+			if key == "" {
+				continue
+			}
+			err := app.blobs.Delete(ctx, key)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"blob_key": key})
+			}
+		}
+	}
+}
+
+// serveImageHandler streams a stored image to a client allowed to read
+// movies. Only keys recorded against a live movie are served, which keeps
+// out uploads still being written and the images of movies in the trash.
+// Keys are random, so a blob never changes once written and can be cached
+// indefinitely, though only privately since it needs a token.
+func (app *application) serveImageHandler(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(httprouter.ParamsFromContext(r.Context()).ByName("key"), "/")
+	err := app.models.Images.CheckKey(key)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	rc, err := app.blobs.Open(r.Context(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, blob.ErrNotFound), errors.Is(err, blob.ErrInvalidKey):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(key)))
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	_, err = io.Copy(w, rc)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cinlim.bikraj.net/internal/data"
+	"cinlim.bikraj.net/internal/validator"
+)
+
+const (
+	// An import body may be far larger than the 1 MB readJSON allows.
+	maxImportBytes = 64 << 20
+	// Valid rows are written in transactions of this many movies.
+	importBatchSize = 500
+	// An NDJSON row longer than this is rejected without being parsed.
+	maxImportLineBytes = 1 << 20
+)
+
+// importRow is one line of the import report.
+type importRow struct {
+	Line   int               `json:"line"`
+	ID     int64             `json:"id,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// movieRowReader returns the next row of an import body with its line
+// number. A row that cannot be parsed is reported through rowErrors rather
+// than err, which is reserved for failures that end the import. It returns
+// io.EOF once the body is exhausted.
+type movieRowReader func() (line int, movie *data.Movie, rowErrors map[string]string, err error)
+
+func (app *application) importMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+	dryRun := app.readBool(qs, "dry_run", false, v)
+	// force=true skips the duplicate check, as it does on create.
+	force := app.readBool(qs, "force", false, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	format := app.readString(qs, "format", "")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "text/csv":
+			format = "csv"
+		case "application/x-ndjson", "application/ndjson", "application/jsonl":
+			format = "ndjson"
+		}
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxImportBytes)
+	var next movieRowReader
+	switch format {
+	case "csv":
+		var err error
+		next, err = csvMovieReader(body)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	case "ndjson":
+		next = ndjsonMovieReader(body)
+	default:
+		app.unsupportedMediaTypeResponse(w, r, "text/csv", "application/x-ndjson")
+		return
+	}
+
+	resolver, err := app.models.Genres.Resolver()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	accepted := []importRow{}
+	rejected := []importRow{}
+	var batch []*data.Movie
+	var batchLines []int
+	seen := make(map[string]bool)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if !dryRun {
+			err := app.models.Movies.InsertBatch(batch, app.contextGetUser(r).ID)
+			if err != nil {
+				return err
+			}
+		}
+		for i, movie := range batch {
+			accepted = append(accepted, importRow{Line: batchLines[i], ID: movie.Id})
+		}
+		batch, batchLines = batch[:0], batchLines[:0]
+		return nil
+	}
+
+	// stop ends an import part way through. Batches already written stay
+	// written, so the client still gets the report so far, with the rows
+	// that were waiting to be saved marked as rejected.
+	stop := func(status int, message string) {
+		for _, line := range batchLines {
+			rejected = append(rejected, importRow{Line: line, Errors: map[string]string{"row": "not saved, the import stopped before this row was written"}})
+		}
+		report := envelope{
+			"dry_run":   dryRun,
+			"completed": false,
+			"accepted":  accepted,
+			"rejected":  rejected,
+		}
+		err := app.writeJSON(w, status, envelope{"error": message, "import": report}, nil)
+		if err != nil {
+			app.logError(r, err)
+		}
+	}
+
+	for {
+		line, movie, rowErrors, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				err = fmt.Errorf("body must not be larger than %d bytes", maxImportBytes)
+			}
+			stop(http.StatusBadRequest, err.Error())
+			return
+		}
+		if rowErrors == nil {
+			v := validator.New()
+			data.NormalizeMovieGenres(v, resolver, movie)
+			if data.ValidateMovie(v, movie); !v.Valid() {
+				rowErrors = v.Errors
+			}
+		}
+		if rowErrors == nil && !force {
+			rowErrors, err = app.importDuplicateErrors(movie, seen)
+			if err != nil {
+				app.logError(r, err)
+				stop(http.StatusInternalServerError, "the server encountered a problem and stopped the import")
+				return
+			}
+		}
+		if rowErrors != nil {
+			rejected = append(rejected, importRow{Line: line, Errors: rowErrors})
+			continue
+		}
+
+		seen[importKey(movie)] = true
+		batch = append(batch, movie)
+		batchLines = append(batchLines, line)
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				app.logError(r, err)
+				stop(http.StatusInternalServerError, "the server encountered a problem and stopped the import")
+				return
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		app.logError(r, err)
+		stop(http.StatusInternalServerError, "the server encountered a problem and stopped the import")
+		return
+	}
+
+	report := envelope{
+		"dry_run":   dryRun,
+		"completed": true,
+		"accepted":  accepted,
+		"rejected":  rejected,
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"import": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// importDuplicateErrors runs the duplicate check createMovieHandler makes
+// against a row, returning the row's errors when it looks like a movie we
+// already have. Earlier rows of the import may not be saved yet, so they are
+// compared by exact title and year through seen, keyed by importKey.
+func (app *application) importDuplicateErrors(movie *data.Movie, seen map[string]bool) (map[string]string, error) {
+	if seen[importKey(movie)] {
+		return map[string]string{"duplicate": "repeats an earlier row of this import, set force to import it anyway"}, nil
+	}
+	candidates, err := app.models.Movies.FindDuplicates(movie.Title, movie.Year)
+	if err != nil || len(candidates) == 0 {
+		return nil, err
+	}
+	return map[string]string{"duplicate": fmt.Sprintf("looks like movie %d, set force to import it anyway", candidates[0].ID)}, nil
+}
+
+func importKey(movie *data.Movie) string {
+	return fmt.Sprintf("%d:%s", movie.Year, strings.ToLower(movie.Title))
+}
+
+// ndjsonMovieReader reads one movie object per line, in the same shape
+// createMovieHandler accepts. Blank lines are skipped, and a line longer than
+// maxImportLineBytes is reported as a rejected row without being buffered.
+func ndjsonMovieReader(body io.Reader) movieRowReader {
+	reader := bufio.NewReaderSize(body, 64*1024)
+	line := 0
+
+	return func() (int, *data.Movie, map[string]string, error) {
+		for {
+			raw, tooLong, err := readImportLine(reader)
+			if err != nil && !errors.Is(err, io.EOF) {
+				return line, nil, nil, err
+			}
+			if errors.Is(err, io.EOF) && len(raw) == 0 && !tooLong {
+				return line, nil, nil, io.EOF
+			}
+			line++
+			if tooLong {
+				return line, nil, map[string]string{"row": fmt.Sprintf("must not be longer than %d bytes", maxImportLineBytes)}, nil
+			}
+			raw = bytes.TrimSpace(raw)
+			if len(raw) == 0 {
+				continue
+			}
+
+			var input struct {
+				Title            string       `json:"title"`
+				Year             int32        `json:"year"`
+				Runtime          data.Runtime `json:"runtime"`
+				Genres           []string     `json:"genres"`
+				OriginalLanguage string       `json:"original_language"`
+			}
+			dec := json.NewDecoder(bytes.NewReader(raw))
+			dec.DisallowUnknownFields()
+			err = dec.Decode(&input)
+			if err != nil {
+				return line, nil, map[string]string{"row": err.Error()}, nil
+			}
+			movie := &data.Movie{
+				Title:            input.Title,
+				Year:             input.Year,
+				Runtime:          input.Runtime,
+				Genres:           input.Genres,
+				OriginalLanguage: input.OriginalLanguage,
+			}
+			return line, movie, nil, nil
+		}
+	}
+}
+
+// readImportLine reads up to and including the next newline. Once a line
+// passes maxImportLineBytes the rest of it is read and dropped, and tooLong
+// is set. err is io.EOF when the body ends, possibly after a final line
+// without a newline.
+func readImportLine(reader *bufio.Reader) (raw []byte, tooLong bool, err error) {
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if !tooLong {
+			if len(raw)+len(chunk) > maxImportLineBytes {
+				raw, tooLong = nil, true
+			} else {
+				raw = append(raw, chunk...)
+			}
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return raw, tooLong, err
+		}
+	}
+}
+
+// csvMovieReader reads a CSV body whose header names the title, year,
+// runtime and genres columns, and optionally original_language. Genres
+// within a cell are separated by "|".
+func csvMovieReader(body io.Reader) (movieRowReader, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("body cannot be empty")
+		}
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"title", "year", "runtime", "genres"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("csv header must contain a %q column", name)
+		}
+	}
+
+	return func() (int, *data.Movie, map[string]string, error) {
+		record, err := reader.Read()
+		if err != nil {
+			var parseError *csv.ParseError
+			if errors.As(err, &parseError) {
+				return parseError.Line, nil, map[string]string{"row": parseError.Err.Error()}, nil
+			}
+			return 0, nil, nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		cell := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		rowErrors := make(map[string]string)
+		movie := &data.Movie{Title: cell("title"), OriginalLanguage: cell("original_language")}
+
+		year, err := strconv.ParseInt(cell("year"), 10, 32)
+		if err != nil {
+			rowErrors["year"] = "must be an integer value"
+		}
+		movie.Year = int32(year)
+
+		movie.Runtime, err = data.ParseRuntime(cell("runtime"))
+		if err != nil {
+			rowErrors["runtime"] = err.Error()
+		}
+
+		if genres := cell("genres"); genres != "" {
+			for _, genre := range strings.Split(genres, "|") {
+				movie.Genres = append(movie.Genres, strings.TrimSpace(genre))
+			}
+		}
+
+		if len(rowErrors) > 0 {
+			return line, nil, rowErrors, nil
+		}
+		return line, movie, nil, nil
+	}, nil
+}
@@ -12,6 +12,7 @@ import (
 	"sync"
 	"time"
 
+	"cinlim.bikraj.net/internal/blob"
 	"cinlim.bikraj.net/internal/data"
 	"cinlim.bikraj.net/internal/jsonlog"
 	"cinlim.bikraj.net/internal/mailer"
@@ -33,9 +34,11 @@ type config struct {
 		MaxIdleTime  string
 	}
 	limiter struct {
-		rps     float64
-		burst   int
-		enabled bool
+		rps          float64
+		burst        int
+		enabled      bool
+		suggestRPS   float64
+		suggestBurst int
 	}
 	smtp struct {
 		host     string
@@ -47,6 +50,20 @@ type config struct {
 	cors struct {
 		trustedOrigins []string
 	}
+	trash struct {
+		retention     time.Duration
+		purgeInterval time.Duration
+	}
+	preconditions struct {
+		required bool
+	}
+	images struct {
+		dir      string
+		baseURL  string
+		maxBytes int64
+		// thumbnailWorkers is how many thumbnails may be made at once.
+		thumbnailWorkers int
+	}
 }
 
 type application struct {
@@ -54,7 +71,11 @@ type application struct {
 	logger *jsonlog.Logger
 	models data.Models
 	mailer mailer.Mailer
+	blobs  blob.Store
 	wg     sync.WaitGroup
+	// thumbnails holds a token for each thumbnail being made, bounding how
+	// many images are decoded at once. Uploads that find it full are refused.
+	thumbnails chan struct{}
 }
 
 func main() {
@@ -72,6 +93,8 @@ func main() {
 	flag.BoolVar(&cfg.limiter.enabled, "rate-enabled", true, "Enable Rate Limitter")
 	flag.IntVar(&cfg.limiter.burst, "burst", 4, "Rate Limiter maximum burst")
 	flag.Float64Var(&cfg.limiter.rps, "limiter rps", 2, "Rate Limiter maximum burst")
+	flag.Float64Var(&cfg.limiter.suggestRPS, "suggest-rps", 10, "Rate Limiter requests per second for title suggestions")
+	flag.IntVar(&cfg.limiter.suggestBurst, "suggest-burst", 20, "Rate Limiter maximum burst for title suggestions")
 
 	// Flag for Smtp Details
 	flag.StringVar(&cfg.smtp.host, "smtp-host", "sandbox.smtp.mailtrap.io", "SMTP host")
@@ -84,6 +107,19 @@ func main() {
 		return nil
 	})
 
+	// Flags for the movie trash
+	flag.DurationVar(&cfg.trash.retention, "trash-retention", 30*24*time.Hour, "How long deleted movies are kept before being purged (0 keeps them forever)")
+	flag.DurationVar(&cfg.trash.purgeInterval, "trash-purge-interval", time.Hour, "How often the trash is purged")
+
+	// Flag for conditional writes
+	flag.BoolVar(&cfg.preconditions.required, "require-if-match", false, "Reject movie updates and deletes that are sent without If-Match (428)")
+
+	// Flags for movie image uploads
+	flag.StringVar(&cfg.images.dir, "images-dir", "./uploads", "Directory uploaded movie images are stored in")
+	flag.StringVar(&cfg.images.baseURL, "images-base-url", "/v1/images", "URL prefix stored images are served from")
+	flag.Int64Var(&cfg.images.maxBytes, "images-max-bytes", 10<<20, "Largest accepted image upload in bytes")
+	flag.IntVar(&cfg.images.thumbnailWorkers, "images-thumbnail-workers", 2, "How many image thumbnails may be made at once; further uploads get 503")
+
 	displayVersion := flag.Bool("version", false, "Display version and exit")
 
 	flag.Parse()
@@ -118,10 +154,12 @@ func main() {
 	}))
 	expvar.NewString("Version").Set(version)
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: models,
-		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender)}
+		config:     cfg,
+		logger:     logger,
+		models:     models,
+		blobs:      blob.NewLocal(cfg.images.dir, cfg.images.baseURL),
+		thumbnails: make(chan struct{}, max(1, cfg.images.thumbnailWorkers)),
+		mailer:     mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender)}
 	err = app.serve()
 	if err != nil {
 		logger.PrintFatal(err, nil)
@@ -28,7 +28,30 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// suggestPath has its own, larger budget, since a search box sends a request
+// on every keystroke.
+const suggestPath = "/v1/movie/suggest"
+
+// rateLimit charges each request to the budget for its path: title
+// suggestions to theirs and everything else to the general one. Both are
+// applied here, ahead of authenticate, so a flood of requests is turned away
+// before any token is looked up.
 func (app *application) rateLimit(next http.Handler) http.Handler {
+	limited := app.ipRateLimiter(app.config.limiter.rps, app.config.limiter.burst)(next)
+	suggestLimited := app.ipRateLimiter(app.config.limiter.suggestRPS, app.config.limiter.suggestBurst)(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == suggestPath {
+			suggestLimited.ServeHTTP(w, r)
+			return
+		}
+		limited.ServeHTTP(w, r)
+	})
+}
+
+// ipRateLimiter returns middleware that gives each client IP its own token
+// bucket holding burst requests and refilling at rps.
+func (app *application) ipRateLimiter(rps float64, burst int) func(http.Handler) http.Handler {
 	type client struct {
 		limiter  *rate.Limiter
 		lastSeen time.Time
@@ -52,26 +75,28 @@ func (app *application) rateLimit(next http.Handler) http.Handler {
 			mu.Unlock()
 		}
 	}()
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if app.config.limiter.enabled {
-			ip := realip.FromRequest(r)
-			mu.Lock()
-			if _, found := clients[ip]; !found {
-				clients[ip] = &client{limiter: rate.NewLimiter(rate.Limit(app.config.limiter.rps), app.config.limiter.burst)}
-			}
-			clients[ip].lastSeen = time.Now()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if app.config.limiter.enabled {
+				ip := realip.FromRequest(r)
+				mu.Lock()
+				if _, found := clients[ip]; !found {
+					clients[ip] = &client{limiter: rate.NewLimiter(rate.Limit(rps), burst)}
+				}
+				clients[ip].lastSeen = time.Now()
+
+				if !clients[ip].limiter.Allow() {
+					mu.Unlock()
+					app.rateLimitExceededResponse(w, r)
+					return
+				}
 
-			if !clients[ip].limiter.Allow() {
 				mu.Unlock()
-				app.rateLimitExceededResponse(w, r)
-				return
 			}
+			next.ServeHTTP(w, r)
 
-			mu.Unlock()
-		}
-		next.ServeHTTP(w, r)
-
-	})
+		})
+	}
 }
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -161,6 +186,16 @@ func (app *application) requirePermission(code string, next http.HandlerFunc) ht
 	return app.requireActivatedUser(fn)
 }
 
+// hasPermission reports whether the user making r holds code. It is for
+// handlers with optional parts that need more than the route's permission.
+func (app *application) hasPermission(r *http.Request, code string) (bool, error) {
+	permissions, err := app.models.Permission.GetAllForUser(app.contextGetUser(r).ID)
+	if err != nil {
+		return false, err
+	}
+	return permissions.Include(code), nil
+}
+
 func (app *application) enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Origin")
@@ -172,9 +207,10 @@ func (app *application) enableCORS(next http.Handler) http.Handler {
 			for i := range app.config.cors.trustedOrigins {
 				if origin == app.config.cors.trustedOrigins[i] {
 					w.Header().Set("Access-Control-Allow-Origin", origin)
+					w.Header().Set("Access-Control-Expose-Headers", "ETag")
 					if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
 						w.Header().Set("Access-Control-Request-Methods", "OPTIONS, PUT, PATCH, DELETE")
-						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-Match, If-None-Match, Runtime-Format")
 
 						w.WriteHeader(http.StatusOK)
 						return
@@ -3,8 +3,10 @@ package main
 import (
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
-	"strconv"
+	"net/url"
+	"strings"
 
 	"cinlim.bikraj.net/internal/data"
 	"cinlim.bikraj.net/internal/validator"
@@ -17,32 +19,110 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 		Year    int32        `json:"year"`
 		Runtime data.Runtime `json:"runtime"`
 		Genres  []string     `json:"genres"`
+		// OriginalLanguage is the language tag of title, such as fr.
+		OriginalLanguage string `json:"original_language"`
+		// ExternalIDs are ids for the movie in other catalogues, keyed by
+		// namespace.
+		ExternalIDs map[string]string `json:"external_ids"`
 	}
 
 	err := app.readJSON(w, r, &input)
 	if err != nil {
-		app.badRequestResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrInvalidRuntimeFormat):
+			app.failedValidationResponse(w, r, map[string]string{"runtime": err.Error()})
+		default:
+			app.badRequestResponse(w, r, err)
+		}
 		return
 	}
 	v := validator.New()
+	qs := r.URL.Query()
+	force := app.readBool(qs, "force", false, v)
+	upsert := app.readBool(qs, "upsert", false, v)
 	movie := &data.Movie{
-		Title:   input.Title,
-		Year:    input.Year,
-		Runtime: input.Runtime,
-		Genres:  input.Genres,
+		Title:            input.Title,
+		Year:             input.Year,
+		Runtime:          input.Runtime,
+		Genres:           input.Genres,
+		OriginalLanguage: input.OriginalLanguage,
+		ExternalIDs:      input.ExternalIDs,
 	}
-	if data.ValidateMovie(v, movie); !v.Valid() {
+	resolver, err := app.models.Genres.Resolver()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	data.NormalizeMovieGenres(v, resolver, movie)
+	data.ValidateMovie(v, movie)
+	data.ValidateExternalIDs(v, movie.ExternalIDs)
+	v.Check(!upsert || len(movie.ExternalIDs) > 0, "external_ids", "must be provided when upsert is set")
+	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	err = app.models.Movies.Insert(movie)
+	// An external id we already hold means the movie exists. upsert=true
+	// updates that movie in place; otherwise the create is refused. It
+	// reports whether it wrote the response.
+	matchExternalIDs := func() bool {
+		matches, err := app.models.External.FindMovies(movie.ExternalIDs)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return true
+		}
+		switch {
+		case len(matches) > 1:
+			app.errorResponse(w, r, http.StatusConflict, envelope{"message": "external ids belong to more than one movie", "movie_ids": matches})
+		case len(matches) == 1 && upsert:
+			app.upsertMovie(w, r, matches[0], movie)
+		case len(matches) == 1:
+			v.AddError("external_ids", fmt.Sprintf("already belong to movie %d, set upsert to update it", matches[0]))
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			return false
+		}
+		return true
+	}
+	if len(movie.ExternalIDs) > 0 && matchExternalIDs() {
+		return
+	}
+	// force=true creates the movie even when it looks like one we have. The
+	// check and the insert are separate statements, so a similar movie
+	// created in between is not caught here; see duplicateMovieResponse.
+	if !force {
+		candidates, err := app.models.Movies.FindDuplicates(movie.Title, movie.Year)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if len(candidates) > 0 {
+			app.duplicateMovieResponse(w, r, candidates)
+			return
+		}
+	}
+	err = app.models.Movies.Insert(movie, app.contextGetUser(r).ID)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrDuplicateExternalID):
+			// Another request saved one of the ids since we looked. Look
+			// again so an upsert updates the movie that now holds it.
+			// Finding none means the id belongs to a movie in the trash.
+			if matchExternalIDs() {
+				return
+			}
+			v.AddError("external_ids", err.Error())
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("v1/movies/%d", movie.Id))
-	err = app.writeJSON(w, http.StatusOK, envelope{"Movie": movie}, headers)
+	// A create is always 201, also for an upsert that matched no movie,
+	// whatever If-Match it sent; an upsert that updates answers 200 instead.
+	app.setRuntimeFormat(r, movie)
+	err = app.writeJSON(w, http.StatusCreated, envelope{"Movie": movie}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -54,7 +134,45 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 		http.NotFound(w, r)
 		return
 	}
-	movie, err := app.models.Movies.Get(id)
+	v := validator.New()
+	qs := r.URL.Query()
+	asOf := app.readTime(qs, "as_of", v)
+	embed := app.readCsv(qs, "embed", []string{})
+	for _, relation := range embed {
+		v.Check(validator.In(relation, "credits", "images", "external_ids"), "embed", "must only contain credits, images or external_ids")
+	}
+	fields := app.readFields(qs, "fields", data.MovieFields, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	// Credits name people, which are guarded by their own permission.
+	if validator.In("credits", embed...) {
+		permitted, err := app.hasPermission(r, "people:read")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !permitted {
+			app.notPermittedResponse(w, r)
+			return
+		}
+	}
+
+	var movie *data.Movie
+	if asOf.IsZero() {
+		movie, err = app.models.Movies.GetFields(id, fields)
+	} else {
+		// Read the movie as it stood at as_of from its revision history.
+		var revision *data.MovieRevision
+		revision, err = app.models.Revisions.GetAsOf(id, asOf)
+		if err == nil && revision.Operation == data.RevisionDelete {
+			err = data.ErrNoRecordFound
+		}
+		if err == nil {
+			movie = revision.Movie()
+		}
+	}
 
 	if err != nil {
 		switch {
@@ -66,7 +184,72 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 		return
 
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"movieHere": movie}, nil)
+	w.Header().Add("Vary", "Accept-Language")
+	err = app.models.Titles.Localize([]*data.Movie{movie}, acceptLanguages(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if movie.TitleLanguage != "" {
+		w.Header().Set("Content-Language", movie.TitleLanguage)
+	}
+
+	if validator.In("credits", embed...) {
+		movie.Credits, err = app.models.Credits.GetForMovie(id)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if len(fields) > 0 {
+			fields = append(fields, "credits")
+		}
+	}
+	if validator.In("images", embed...) {
+		movie.Images, err = app.models.Images.GetForMovie(id)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		app.imageURLs(movie.Images...)
+		if len(fields) > 0 {
+			fields = append(fields, "images")
+		}
+	}
+	if validator.In("external_ids", embed...) {
+		movie.ExternalIDs, err = app.models.External.GetForMovie(id)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if len(fields) > 0 {
+			fields = append(fields, "external_ids")
+		}
+	}
+	app.setRuntimeFormat(r, movie)
+	body, err := pickFields(movie, fields)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// The version-based tag only describes the full movie on its own;
+	// localized titles, credits and images change without touching the
+	// version, so other representations add a hash of the body as a variant.
+	// The variant tag is still accepted by If-Match on PATCH and DELETE. A
+	// runtime format other than the default is a variant of its own.
+	etag := runtimeETag(movieETag(movie), movie.RuntimeFormat)
+	if len(fields) > 0 || len(embed) > 0 || movie.TitleLanguage != "" {
+		hash, err := hashETag(body)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		etag = variantETag(etag, hash)
+	}
+	if app.notModified(w, r, etag) {
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"movieHere": body}, nil)
 
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -77,6 +260,7 @@ func (app *application) updateHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil {
 		app.notFoundResponse(w, r)
+		return
 	}
 	movie, err := app.models.Movies.Get(id)
 	if err != nil {
@@ -89,44 +273,68 @@ func (app *application) updateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if r.Header.Get("X-Expected-Version") != "" {
-		if strconv.FormatInt(int64(movie.Version), 32) != r.Header.Get("X-Expected-Version") {
-			app.editConflictResponse(w, r)
-			return
-		}
-	}
-	var input struct {
-		Title   *string       `json:"title"`
-		Year    *int32        `json:"year"`
-		Runtime *data.Runtime `json:"runtime"`
-		Genres  []string      `json:"genres"`
-	}
-	err = app.readJSON(w, r, &input)
-	if err != nil {
-		app.badRequestResponse(w, r, err)
+	if !app.checkIfMatch(w, r, movieETag(movie)) {
 		return
 	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/merge-patch+json", "application/json-patch+json":
+		if !app.patchMovie(w, r, movie, mediaType) {
+			return
+		}
+	case "", "application/json":
+		var input struct {
+			Title   *string       `json:"title"`
+			Year    *int32        `json:"year"`
+			Runtime *data.Runtime `json:"runtime"`
+			Genres  []string      `json:"genres"`
+			// OriginalLanguage is set to "" to say it is unknown.
+			OriginalLanguage *string `json:"original_language"`
+		}
+		err = app.readJSON(w, r, &input)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrInvalidRuntimeFormat):
+				app.failedValidationResponse(w, r, map[string]string{"runtime": err.Error()})
+			default:
+				app.badRequestResponse(w, r, err)
+			}
+			return
+		}
 
-	if input.Title != nil {
-		movie.Title = *input.Title
-	}
-	// We also do the same for the other fields in the input struct.
-	if input.Year != nil {
-		movie.Year = *input.Year
-	}
-	if input.Runtime != nil {
-		movie.Runtime = *input.Runtime
-	}
-	if input.Genres != nil {
-		movie.Genres = input.Genres // Note that we don't need to dereference a slice.
+		if input.Title != nil {
+			movie.Title = *input.Title
+		}
+		// We also do the same for the other fields in the input struct.
+		if input.Year != nil {
+			movie.Year = *input.Year
+		}
+		if input.Runtime != nil {
+			movie.Runtime = *input.Runtime
+		}
+		if input.Genres != nil {
+			movie.Genres = input.Genres // Note that we don't need to dereference a slice.
+		}
+		if input.OriginalLanguage != nil {
+			movie.OriginalLanguage = *input.OriginalLanguage
+		}
+	default:
+		app.unsupportedMediaTypeResponse(w, r, "application/json", "application/merge-patch+json", "application/json-patch+json")
+		return
 	}
 
 	v := validator.New()
+	resolver, err := app.models.Genres.Resolver()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	data.NormalizeMovieGenres(v, resolver, movie)
 	if data.ValidateMovie(v, movie); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	err = app.models.Movies.Update(movie)
+	err = app.models.Movies.Update(movie, app.contextGetUser(r).ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
@@ -136,7 +344,10 @@ func (app *application) updateHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"Movie": movie}, nil)
+	app.setRuntimeFormat(r, movie)
+	headers := make(http.Header)
+	headers.Set("ETag", runtimeETag(movieETag(movie), movie.RuntimeFormat))
+	err = app.writeJSON(w, http.StatusOK, envelope{"Movie": movie}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -149,11 +360,31 @@ func (app *application) deleteHanlder(w http.ResponseWriter, r *http.Request) {
 		app.notFoundResponse(w, r)
 		return
 	}
-	err = app.models.Movies.Delete(id)
+	// The movie is only read when the delete is conditional.
+	var version int32
+	if r.Header.Get("If-Match") != "" || app.config.preconditions.required {
+		movie, err := app.models.Movies.Get(id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrNoRecordFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		if !app.checkIfMatch(w, r, movieETag(movie)) {
+			return
+		}
+		version = movie.Version
+	}
+	err = app.models.Movies.Delete(id, version, app.contextGetUser(r).ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrNoRecordFound):
 			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.preconditionFailedResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -169,37 +400,122 @@ func (app *application) deleteHanlder(w http.ResponseWriter, r *http.Request) {
 
 func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title  string
-		Genres []string
+		data.MovieQuery
 		data.Filter
+		Facets []string
 	}
 	v := validator.New()
 
 	qs := r.URL.Query()
-	input.Title = app.readString(qs, "title", "")
-	input.Genres = app.readCsv(qs, "genres", []string{})
+	// ids= turns the list into a batch fetch of exactly those movies.
+	if qs.Has("ids") {
+		ids := app.readIDs(app.readCsv(qs, "ids", []string{}), v)
+		images := app.readImagesEmbed(qs, v)
+		if validateBatchIDs(v, ids); !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+		app.writeMovieBatch(w, r, ids, images)
+		return
+	}
+
+	input.MovieQuery = app.readMovieQuery(qs, v)
+	input.Facets = app.readCsv(qs, "facets", []string{})
+	fields := app.readFields(qs, "fields", data.MovieListFields, v)
+	images := app.readImagesEmbed(qs, v)
 
 	input.Filter.Page = app.readInt(qs, "page", 1, v)
 	input.Filter.PageSize = app.readInt(qs, "page_size", 20, v)
 
 	input.Filter.Sort = app.readString(qs, "sort", "id")
+	input.Filter.Cursor = app.readString(qs, "cursor", app.readString(qs, "after", ""))
+	// Offset paging keeps its total by default; cursor paging skips the
+	// window count unless include_total is asked for.
+	input.Filter.IncludeTotal = app.readBool(qs, "include_total", input.Filter.Cursor == "", v)
 
-	input.Filter.SortSafeList = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+	input.Filter.SortSafeList = movieSortSafeList
 
 	data.ValidateFilters(v, input.Filter)
+	data.ValidateMovieQuery(v, input.MovieQuery)
+	v.Check(strings.TrimPrefix(input.Filter.Sort, "-") != "relevance" || input.Title != "", "sort", "relevance sort requires a title search")
+	for _, facet := range input.Facets {
+		v.Check(validator.In(facet, data.MovieFacets...), "facets", "must only contain genres, decade or runtime_bucket")
+	}
+	v.Check(validator.Unique(input.Facets), "facets", "must not contain duplicate values")
 
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	movies, metadata, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filter)
+	movies, metadata, err := app.models.Movies.GetAll(input.MovieQuery, input.Filter, fields)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	w.Header().Add("Vary", "Accept-Language")
+	err = app.models.Titles.Localize(movies, acceptLanguages(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if images {
+		err = app.embedImages(movies)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if len(fields) > 0 {
+			fields = append(fields, "images")
+		}
+	}
+	app.setRuntimeFormat(r, movies...)
+	body := make([]any, len(movies))
+	for i, movie := range movies {
+		body[i], err = pickFields(movie, fields)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	env := envelope{"MetatData": metadata, "Movie": body}
+	if len(input.Facets) > 0 {
+		env["facets"], err = app.models.Movies.Facets(input.MovieQuery, input.Facets)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	etag, err := hashETag(env)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"MetatData": metadata, "Movie": movies}, nil)
+	if app.notModified(w, r, etag) {
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, env, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 }
+
+// movieSortSafeList is the set of sort keys accepted wherever movies are listed.
+var movieSortSafeList = []string{"id", "title", "year", "runtime", "relevance", "rating", "-id", "-title", "-year", "-runtime", "-relevance", "-rating"}
+
+// readMovieQuery reads the filters shared by the movie list and export.
+func (app *application) readMovieQuery(qs url.Values, v *validator.Validator) data.MovieQuery {
+	return data.MovieQuery{
+		Title:         app.readString(qs, "title", ""),
+		Genres:        app.readGenres(qs, "genres"),
+		YearMin:       int32(app.readInt(qs, "year_min", 0, v)),
+		YearMax:       int32(app.readInt(qs, "year_max", 0, v)),
+		RuntimeMin:    app.readRuntime(qs, "runtime_min", v),
+		RuntimeMax:    app.readRuntime(qs, "runtime_max", v),
+		CreatedAfter:  app.readTime(qs, "created_after", v),
+		CreatedBefore: app.readTime(qs, "created_before", v),
+		PersonID:      int64(app.readInt(qs, "person_id", 0, v)),
+		Role:          app.readString(qs, "role", ""),
+		Match:         app.readString(qs, "match", "strict"),
+	}
+}
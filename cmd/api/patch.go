@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"cinlim.bikraj.net/internal/data"
+	"cinlim.bikraj.net/internal/jsonpatch"
+	"cinlim.bikraj.net/internal/validator"
+)
+
+// movieDocument is the part of a movie that patches can see and change.
+type movieDocument struct {
+	Title            string       `json:"title"`
+	Year             int32        `json:"year"`
+	Runtime          data.Runtime `json:"runtime"`
+	Genres           []string     `json:"genres"`
+	OriginalLanguage string       `json:"original_language"`
+}
+
+// patchMovie applies a JSON Merge Patch or JSON Patch body to movie. It
+// writes the error response itself and reports whether the handler may
+// carry on.
+func (app *application) patchMovie(w http.ResponseWriter, r *http.Request, movie *data.Movie, mediaType string) bool {
+	// Round-trip through JSON so the document holds the same generic values
+	// the patch does.
+	js, err := json.Marshal(movieDocument{
+		Title:            movie.Title,
+		Year:             movie.Year,
+		Runtime:          movie.Runtime,
+		Genres:           movie.Genres,
+		OriginalLanguage: movie.OriginalLanguage,
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+	var doc any
+	err = json.Unmarshal(js, &doc)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+
+	if mediaType == "application/merge-patch+json" {
+		var patch any
+		err = app.readJSON(w, r, &patch)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return false
+		}
+		doc = jsonpatch.MergePatch(doc, patch)
+	} else {
+		var ops []jsonpatch.Operation
+		err = app.readJSON(w, r, &ops)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return false
+		}
+		doc, err = jsonpatch.Apply(doc, ops)
+		if err != nil {
+			switch {
+			case errors.Is(err, jsonpatch.ErrTestFailed):
+				app.errorResponse(w, r, http.StatusConflict, err.Error())
+			default:
+				app.failedValidationResponse(w, r, map[string]string{"patch": err.Error()})
+			}
+			return false
+		}
+	}
+
+	js, err = json.Marshal(doc)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+	var patched movieDocument
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&patched)
+	if err != nil {
+		v := validator.New()
+		if errors.Is(err, data.ErrInvalidRuntimeFormat) {
+			v.AddError("runtime", err.Error())
+		} else {
+			v.AddError("patch", "result is not a valid movie: "+err.Error())
+		}
+		app.failedValidationResponse(w, r, v.Errors)
+		return false
+	}
+
+	movie.Title = patched.Title
+	movie.Year = patched.Year
+	movie.Runtime = patched.Runtime
+	movie.Genres = patched.Genres
+	movie.OriginalLanguage = patched.OriginalLanguage
+	return true
+}
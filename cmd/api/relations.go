@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"cinlim.bikraj.net/internal/data"
+	"cinlim.bikraj.net/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// relatedMoviesHandler returns the relation graph around a movie, walking
+// depth links out (1 by default), and the collections the movie is in.
+func (app *application) relatedMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	v := validator.New()
+	depth := app.readInt(r.URL.Query(), "depth", 1, v)
+	v.Check(depth >= 1, "depth", "must be greater than zero")
+	v.Check(depth <= data.MaxRelationDepth, "depth", fmt.Sprintf("must be a maximum of %d", data.MaxRelationDepth))
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	graph, err := app.models.Relations.GetGraph(id, depth)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	collections, err := app.models.Collection.GetForMovie(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": graph.Movies, "relations": graph.Relations, "collections": collections}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createRelationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		RelatedMovieID int64  `json:"related_movie_id"`
+		Kind           string `json:"kind"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	relation := &data.Relation{
+		MovieID:        id,
+		RelatedMovieID: input.RelatedMovieID,
+		Kind:           input.Kind,
+	}
+	v := validator.New()
+	if data.ValidateRelation(v, relation); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	exists, err := app.movieExists(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !exists {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Relations.Insert(relation)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			v.AddError("related_movie_id", "no movie exists with this id")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateRelation):
+			v.AddError("related_movie_id", "movies are already linked by this relation")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusCreated, envelope{"relation": relation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteRelationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	relationID, err := strconv.ParseInt(httprouter.ParamsFromContext(r.Context()).ByName("relation_id"), 10, 64)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	err = app.models.Relations.Delete(id, relationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "Relation Removed Successfully"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"cinlim.bikraj.net/internal/data"
+	"cinlim.bikraj.net/internal/validator"
+)
+
+func (app *application) movieHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		data.Filter
+	}
+	v := validator.New()
+
+	qs := r.URL.Query()
+	input.Filter.Page = app.readInt(qs, "page", 1, v)
+	input.Filter.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filter.Sort = app.readString(qs, "sort", "-version")
+	input.Filter.SortSafeList = []string{"version", "created_at", "-version", "-created_at"}
+
+	if data.ValidateFilters(v, input.Filter); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	revisions, metadata, err := app.models.Revisions.GetAllForMovie(id, input.Filter)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	format := app.contextGetRuntimeFormat(r)
+	for _, revision := range revisions {
+		revision.RuntimeFormat = format
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"metadata": metadata, "revisions": revisions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) movieHistoryDiffHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	v := validator.New()
+
+	qs := r.URL.Query()
+	from := app.readInt(qs, "from", 0, v)
+	to := app.readInt(qs, "to", 0, v)
+	v.Check(from > 0, "from", "must be a version greater than zero")
+	v.Check(to > 0, "to", "must be a version greater than zero")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	fromRevision, err := app.models.Revisions.GetVersion(id, int32(from))
+	if err != nil {
+		app.revisionLookupError(w, r, err)
+		return
+	}
+	toRevision, err := app.models.Revisions.GetVersion(id, int32(to))
+	if err != nil {
+		app.revisionLookupError(w, r, err)
+		return
+	}
+
+	diff := envelope{
+		"from":    fromRevision.Version,
+		"to":      toRevision.Version,
+		"changes": fromRevision.Diff(toRevision),
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"diff": diff}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// revertMovieHandler writes an old revision back as a new version. It goes
+// through the usual Update path, so a concurrent edit still conflicts.
+func (app *application) revertMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		Version int32 `json:"version"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	if v.Check(input.Version > 0, "version", "must be greater than zero"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if !app.checkIfMatch(w, r, movieETag(movie)) {
+		return
+	}
+	revision, err := app.models.Revisions.GetVersion(id, input.Version)
+	if err != nil {
+		app.revisionLookupError(w, r, err)
+		return
+	}
+
+	movie.Title = revision.Title
+	movie.Year = revision.Year
+	movie.Runtime = revision.Runtime
+	movie.Genres = revision.Genres
+	movie.OriginalLanguage = revision.OriginalLanguage
+
+	// Revisions from before the genre catalogue may hold free-text spellings.
+	resolver, err := app.models.Genres.Resolver()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	data.NormalizeMovieGenres(v, resolver, movie)
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Movies.Update(movie, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.setRuntimeFormat(r, movie)
+	headers := make(http.Header)
+	headers.Set("ETag", runtimeETag(movieETag(movie), movie.RuntimeFormat))
+	err = app.writeJSON(w, http.StatusOK, envelope{"Movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) revisionLookupError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, data.ErrNoRecordFound):
+		app.notFoundResponse(w, r)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
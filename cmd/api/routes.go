@@ -14,20 +14,104 @@ func (app *application) routes() http.Handler {
 
 	router.NotFound = http.HandlerFunc(app.notFoundResponse)
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
+	// fixed holds routes whose fixed names, such as /v1/movie/export, sit
+	// where router already has a wildcard. httprouter will not register both,
+	// so fixed is tried first and hands anything it does not match, whatever
+	// the method, on to router.
+	fixed := httprouter.New()
+	fixed.NotFound = router
+	fixed.HandleMethodNotAllowed = false
+	fixed.RedirectTrailingSlash = false
+	fixed.RedirectFixedPath = false
+
 	router.HandlerFunc(http.MethodGet, "/v1/healthCheck", app.healthCheckHandler)
 	// CRUD for Movie
-	router.HandlerFunc(http.MethodGet, "/v1/movie", app.requirePermission("movies:read", app.listMoviesHandler))
-	router.HandlerFunc(http.MethodPost, "/v1/movie", app.requirePermission("movies:write", app.createMovieHandler))
-	router.HandlerFunc(http.MethodGet, "/v1/movie/:id", app.requirePermission("movies:read", app.showMovieHandler))
-	router.HandlerFunc(http.MethodPatch, "/v1/movie/:id", app.requirePermission("movies:write", app.updateHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movie", app.requirePermission("movies:read", app.runtimeFormat(app.listMoviesHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/movie", app.requirePermission("movies:write", app.runtimeFormat(app.createMovieHandler)))
+	fixed.HandlerFunc(http.MethodGet, "/v1/movie/export", app.requirePermission("movies:read", app.runtimeFormat(app.exportMoviesHandler)))
+	fixed.HandlerFunc(http.MethodGet, "/v1/movie/trash", app.requirePermission("movies:write", app.runtimeFormat(app.listTrashHandler)))
+	fixed.HandlerFunc(http.MethodGet, "/v1/movie/duplicates", app.requirePermission("movies:write", app.listDuplicatesHandler))
+	fixed.HandlerFunc(http.MethodGet, "/v1/movie/suggest", app.requirePermission("movies:read", app.suggestMoviesHandler))
+	fixed.HandlerFunc(http.MethodGet, "/v1/movie/by-external/:namespace/:value", app.requirePermission("movies:read", app.runtimeFormat(app.showMovieByExternalIDHandler)))
+	fixed.HandlerFunc(http.MethodPost, "/v1/movie/import", app.requirePermission("movies:write", app.importMoviesHandler))
+	fixed.HandlerFunc(http.MethodPost, "/v1/movie/batch-get", app.requirePermission("movies:read", app.runtimeFormat(app.batchGetMoviesHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/movie/:id", app.requirePermission("movies:read", app.runtimeFormat(app.showMovieHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/movie/:id/similar", app.requirePermission("movies:read", app.runtimeFormat(app.similarMoviesHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/movie/:id/history", app.requirePermission("movies:read", app.runtimeFormat(app.movieHistoryHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/movie/:id/history/diff", app.requirePermission("movies:read", app.movieHistoryDiffHandler))
+	// Site-wide settings, for administrators only
+	router.HandlerFunc(http.MethodGet, "/v1/settings/similarity", app.requirePermission("settings:read", app.showSimilarityWeightsHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/settings/similarity", app.requirePermission("settings:write", app.updateSimilarityWeightsHandler))
+	// Reviews, one per user per movie
+	router.HandlerFunc(http.MethodGet, "/v1/movie/:id/reviews", app.requirePermission("movies:read", app.listReviewsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movie/:id/review", app.requireActivatedUser(app.showReviewHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/movie/:id/review", app.requireActivatedUser(app.putReviewHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movie/:id/review", app.requireActivatedUser(app.deleteReviewHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/movie/:id", app.requirePermission("movies:write", app.runtimeFormat(app.updateHandler)))
 	router.HandlerFunc(http.MethodDelete, "/v1/movie/:id", app.requirePermission("movies:write", app.deleteHanlder))
+	router.HandlerFunc(http.MethodPost, "/v1/movie/:id/restore", app.requirePermission("movies:write", app.runtimeFormat(app.restoreMovieHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/movie/:id/revert", app.requirePermission("movies:write", app.runtimeFormat(app.revertMovieHandler)))
+	// Localized titles
+	router.HandlerFunc(http.MethodGet, "/v1/movie/:id/titles", app.requirePermission("movies:read", app.listMovieTitlesHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/movie/:id/titles/:language", app.requirePermission("movies:write", app.putMovieTitleHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movie/:id/titles/:language", app.requirePermission("movies:write", app.deleteMovieTitleHandler))
+
+	// Movie artwork
+	router.HandlerFunc(http.MethodGet, "/v1/movie/:id/images", app.requirePermission("movies:read", app.listMovieImagesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movie/:id/images", app.requirePermission("movies:write", app.uploadMovieImageHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movie/:id/images/:image_id", app.requirePermission("movies:write", app.deleteMovieImageHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/images/*key", app.requirePermission("movies:read", app.serveImageHandler))
+
+	// Credits linking people to movies
+	router.HandlerFunc(http.MethodGet, "/v1/movie/:id/credits", app.requirePermission("movies:read", app.requirePermission("people:read", app.listCreditsHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/movie/:id/credits", app.requirePermission("movies:write", app.createCreditHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movie/:id/credits/:credit_id", app.requirePermission("movies:write", app.deleteCreditHandler))
+	// Ids in other catalogues
+	router.HandlerFunc(http.MethodGet, "/v1/movie/:id/external-ids", app.requirePermission("movies:read", app.listExternalIDsHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/movie/:id/external-ids/:namespace", app.requirePermission("movies:write", app.putExternalIDHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movie/:id/external-ids/:namespace", app.requirePermission("movies:write", app.deleteExternalIDHandler))
+	// Relations between movies, and collections such as trilogies
+	router.HandlerFunc(http.MethodGet, "/v1/movie/:id/related", app.requirePermission("movies:read", app.relatedMoviesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movie/:id/relations", app.requirePermission("movies:write", app.createRelationHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movie/:id/relations/:relation_id", app.requirePermission("movies:write", app.deleteRelationHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/collections", app.requirePermission("movies:read", app.listCollectionsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/collections", app.requirePermission("movies:write", app.createCollectionHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/collections/:id", app.requirePermission("movies:read", app.showCollectionHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/collections/:id", app.requirePermission("movies:write", app.updateCollectionHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/collections/:id", app.requirePermission("movies:write", app.deleteCollectionHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/collections/:id/movies", app.requirePermission("movies:write", app.addCollectionMovieHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/collections/:id/movies/order", app.requirePermission("movies:write", app.reorderCollectionHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/collections/:id/movies/:movie_id", app.requirePermission("movies:write", app.removeCollectionMovieHandler))
+	// CRUD for People
+	router.HandlerFunc(http.MethodGet, "/v1/people", app.requirePermission("people:read", app.listPeopleHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/people", app.requirePermission("people:write", app.createPersonHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/people/:id", app.requirePermission("people:read", app.showPersonHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/people/:id", app.requirePermission("people:write", app.updatePersonHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/people/:id", app.requirePermission("people:write", app.deletePersonHandler))
+
+	// Genre catalogue. Changing it retags movies wholesale, so it has its own
+	// permission.
+	router.HandlerFunc(http.MethodGet, "/v1/genres", app.requirePermission("movies:read", app.listGenresHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/genres", app.requirePermission("genres:write", app.createGenreHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/genres/:id", app.requirePermission("movies:read", app.showGenreHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/genres/:id", app.requirePermission("genres:write", app.updateGenreHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/genres/:id", app.requirePermission("genres:write", app.deleteGenreHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/genres/:id/aliases", app.requirePermission("genres:write", app.addGenreAliasHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/genres/:id/aliases/:alias", app.requirePermission("genres:write", app.removeGenreAliasHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/genres/:id/merge", app.requirePermission("genres:write", app.mergeGenreHandler))
 	//CRUD For User
 	router.HandlerFunc(http.MethodPost, "/v1/user", app.registerUserHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/user/activate", app.activateUserHandler)
 	router.HandlerFunc(http.MethodPost, "/v1/user/authenticate", app.createAuthenticationTokenHandler)
+	// Watchlist of the authenticated user
+	router.HandlerFunc(http.MethodGet, "/v1/user/me/watchlist", app.requirePermission("movies:read", app.runtimeFormat(app.listWatchlistHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/user/me/watchlist", app.requirePermission("movies:read", app.addWatchlistHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/user/me/watchlist/order", app.requirePermission("movies:read", app.reorderWatchlistHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/user/me/watchlist/:movie_id", app.requirePermission("movies:read", app.updateWatchlistHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/user/me/watchlist/:movie_id", app.requirePermission("movies:read", app.removeWatchlistHandler))
 	// Route for Checking metrics
 	router.Handler(http.MethodGet, "/debug/vars", expvar.Handler())
 	// Return the httpRouter Instance
 	standard := alice.New(app.metrics, app.recoverPanic, app.enableCORS, app.rateLimit, app.authenticate)
-	return standard.Then(router)
+	return standard.Then(fixed)
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"cinlim.bikraj.net/internal/data"
+	"cinlim.bikraj.net/internal/validator"
+)
+
+// readRuntimeFormat returns the runtime output format the client asked for,
+// from the runtime_format query parameter or else the Runtime-Format header.
+// It is "mins" when neither is set.
+func (app *application) readRuntimeFormat(r *http.Request) string {
+	format := r.URL.Query().Get("runtime_format")
+	if format == "" {
+		format = r.Header.Get("Runtime-Format")
+	}
+	if format == "" {
+		return "mins"
+	}
+	return strings.ToLower(format)
+}
+
+// runtimeFormat checks the runtime format the client asked for and keeps it
+// in the request context. Handlers apply it to the movies they write through
+// setRuntimeFormat, so the format is used where runtimes are encoded. It
+// wraps only the routes that write runtimes; elsewhere the parameter is
+// ignored.
+func (app *application) runtimeFormat(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Runtime-Format")
+
+		format := app.readRuntimeFormat(r)
+		if !validator.In(format, data.RuntimeFormats...) {
+			v := validator.New()
+			v.AddError("runtime_format", "must be one of "+strings.Join(data.RuntimeFormats, ", "))
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+		next.ServeHTTP(w, app.contextSetRuntimeFormat(r, format))
+	}
+}
+
+// setRuntimeFormat makes movies encode their runtimes in the format the
+// request asked for.
+func (app *application) setRuntimeFormat(r *http.Request, movies ...*data.Movie) {
+	format := app.contextGetRuntimeFormat(r)
+	for _, movie := range movies {
+		if movie != nil {
+			movie.RuntimeFormat = format
+		}
+	}
+}
+
+// runtimeETag folds a runtime format other than the default into etag, since
+// the body differs while the movie does not.
+func runtimeETag(etag, format string) string {
+	if format == "mins" {
+		return etag
+	}
+	return variantETag(etag, format)
+}
@@ -22,6 +22,13 @@ func (app *application) serve() error {
 		ErrorLog:     log.New(app.logger, "", 0),
 	}
 
+	// The purge runs as a background task so shutdown waits for a purge in
+	// progress rather than cutting it off.
+	stopPurge := make(chan struct{})
+	app.background(func() {
+		app.purgeTrash(stopPurge)
+	})
+
 	shutDownErr := make(chan error)
 	go func() {
 		quit := make(chan os.Signal, 1)
@@ -41,6 +48,7 @@ func (app *application) serve() error {
 			"addr": srv.Addr,
 		})
 		// Wait for all the background tasks
+		close(stopPurge)
 		app.wg.Wait()
 		shutDownErr <- nil
 
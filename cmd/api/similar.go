@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"cinlim.bikraj.net/internal/data"
+	"cinlim.bikraj.net/internal/validator"
+)
+
+func (app *application) similarMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		data.Filter
+	}
+	v := validator.New()
+
+	qs := r.URL.Query()
+	input.Filter.Page = app.readInt(qs, "page", 1, v)
+	input.Filter.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filter.Sort = "-score"
+	input.Filter.SortSafeList = []string{"-score"}
+
+	if data.ValidateFilters(v, input.Filter); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	exists, err := app.movieExists(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !exists {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movies, metadata, err := app.models.Movies.GetSimilar(id, input.Filter)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	for _, movie := range movies {
+		app.setRuntimeFormat(r, movie.Movie)
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"metadata": metadata, "Movie": movies}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showSimilarityWeightsHandler(w http.ResponseWriter, r *http.Request) {
+	weights, err := app.models.Similarity.Get()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"weights": weights}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateSimilarityWeightsHandler retunes GET /v1/movie/:id/similar. The new
+// weights apply from the next request.
+func (app *application) updateSimilarityWeightsHandler(w http.ResponseWriter, r *http.Request) {
+	weights, err := app.models.Similarity.Get()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var input struct {
+		Genres  *float32 `json:"genres"`
+		Year    *float32 `json:"year"`
+		Runtime *float32 `json:"runtime"`
+		Title   *float32 `json:"title"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if input.Genres != nil {
+		weights.Genres = *input.Genres
+	}
+	if input.Year != nil {
+		weights.Year = *input.Year
+	}
+	if input.Runtime != nil {
+		weights.Runtime = *input.Runtime
+	}
+	if input.Title != nil {
+		weights.Title = *input.Title
+	}
+
+	v := validator.New()
+	if data.ValidateSimilarityWeights(v, weights); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Similarity.Update(weights)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"weights": weights}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"cinlim.bikraj.net/internal/validator"
+)
+
+// suggestMoviesHandler backs the search box, returning a few titles that
+// complete or resemble what has been typed so far.
+func (app *application) suggestMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+	q := strings.TrimSpace(app.readString(qs, "q", ""))
+	limit := app.readInt(qs, "limit", 10, v)
+
+	v.Check(q != "", "q", "must be provided")
+	v.Check(len(q) <= 100, "q", "must not be more than 100 bytes long")
+	v.Check(limit > 0 && limit <= 25, "limit", "must be between 1 and 25")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	suggestions, err := app.models.Movies.Suggest(q, limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"suggestions": suggestions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cinlim.bikraj.net/internal/data"
+	"cinlim.bikraj.net/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+func (app *application) listMovieTitlesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	titles, err := app.models.Titles.GetForMovie(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"titles": titles}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) putMovieTitleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		Title string `json:"title"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	title := &data.MovieTitle{
+		MovieID:  id,
+		Language: strings.ToLower(httprouter.ParamsFromContext(r.Context()).ByName("language")),
+		Title:    input.Title,
+	}
+	v := validator.New()
+	if data.ValidateMovieTitle(v, title); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	exists, err := app.movieExists(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !exists {
+		app.notFoundResponse(w, r)
+		return
+	}
+	err = app.models.Titles.Upsert(title)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"title": title}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieTitleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	language := strings.ToLower(httprouter.ParamsFromContext(r.Context()).ByName("language"))
+	err = app.models.Titles.Delete(id, language)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "Title Removed Successfully"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// acceptLanguages returns the languages in the request's Accept-Language
+// header, most preferred first. A regional tag is followed by its base
+// language, so "fr-CA" will also take a plain "fr" title. Tags with q=0 and
+// the "*" wildcard are dropped.
+func acceptLanguages(r *http.Request) []string {
+	type tag struct {
+		language string
+		q        float64
+	}
+	var tags []tag
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		language, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		language = strings.ToLower(strings.TrimSpace(language))
+		if language == "" || language == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if key == "q" {
+				parsed, err := strconv.ParseFloat(value, 64)
+				if err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			tags = append(tags, tag{language, q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	var languages []string
+	for _, t := range tags {
+		for language := t.language; language != ""; {
+			if !validator.In(language, languages...) {
+				languages = append(languages, language)
+			}
+			i := strings.LastIndex(language, "-")
+			if i < 0 {
+				break
+			}
+			language = language[:i]
+		}
+	}
+	return languages
+}
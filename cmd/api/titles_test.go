@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestAcceptLanguages(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", nil},
+		{"fr", []string{"fr"}},
+		{"FR-ca", []string{"fr-ca", "fr"}},
+		{"fr-CA, en;q=0.5", []string{"fr-ca", "fr", "en"}},
+		{"en;q=0.5, fr-CA", []string{"fr-ca", "fr", "en"}},
+		{"de;q=0.2, ja;q=0.9, en;q=0.5", []string{"ja", "en", "de"}},
+		{"fr;q=0.8, es;q=0.8", []string{"fr", "es"}},
+		{"fr, fr-CA", []string{"fr", "fr-ca"}},
+		{"zh-Hant-TW", []string{"zh-hant-tw", "zh-hant", "zh"}},
+		{"*", nil},
+		{"fr, *;q=0.5", []string{"fr"}},
+		{"fr;q=0, en", []string{"en"}},
+		{"fr;q=0.000", nil},
+		{"fr;level=1;q=0.4, en;q=0.6", []string{"en", "fr"}},
+		{"fr;q=abc, en;q=0.5", []string{"fr", "en"}},
+		{" , en ,, ", []string{"en"}},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/v1/movie/1", nil)
+		if tt.header != "" {
+			r.Header.Set("Accept-Language", tt.header)
+		}
+		if got := acceptLanguages(r); !slices.Equal(got, tt.want) {
+			t.Errorf("acceptLanguages(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
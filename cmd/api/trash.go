@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cinlim.bikraj.net/internal/data"
+	"cinlim.bikraj.net/internal/validator"
+)
+
+func (app *application) listTrashHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filter
+	}
+	v := validator.New()
+
+	qs := r.URL.Query()
+	input.Filter.Page = app.readInt(qs, "page", 1, v)
+	input.Filter.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filter.Sort = app.readString(qs, "sort", "-deleted_at")
+	input.Filter.SortSafeList = []string{"id", "title", "deleted_at", "-id", "-title", "-deleted_at"}
+
+	if data.ValidateFilters(v, input.Filter); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	movies, metadata, err := app.models.Movies.GetTrash(input.Filter)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.setRuntimeFormat(r, movies...)
+	err = app.writeJSON(w, http.StatusOK, envelope{"metadata": metadata, "Movie": movies}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) restoreMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	// As with delete, the movie is only read when the restore is conditional.
+	var version int32
+	if r.Header.Get("If-Match") != "" || app.config.preconditions.required {
+		movie, err := app.models.Movies.GetDeleted(id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrNoRecordFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		if !app.checkIfMatch(w, r, movieETag(movie)) {
+			return
+		}
+		version = movie.Version
+	}
+	movie, err := app.models.Movies.Restore(id, version, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.preconditionFailedResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.setRuntimeFormat(r, movie)
+	headers := make(http.Header)
+	headers.Set("ETag", runtimeETag(movieETag(movie), movie.RuntimeFormat))
+	err = app.writeJSON(w, http.StatusOK, envelope{"Movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// purgeTrash permanently removes movies that have outlived the trash
+// retention period, once at start up and then every purge interval, until
+// done is closed.
+func (app *application) purgeTrash(done <-chan struct{}) {
+	if app.config.trash.retention <= 0 || app.config.trash.purgeInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(app.config.trash.purgeInterval)
+	defer ticker.Stop()
+	for {
+		purged, err := app.purgeDeleted(time.Now().Add(-app.config.trash.retention))
+		if err != nil {
+			app.logger.PrintError(err, nil)
+		} else if purged > 0 {
+			app.logger.PrintInfo("purged deleted movies", map[string]string{
+				"count": strconv.FormatInt(purged, 10),
+			})
+		}
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeDeleted removes movies that went into the trash before the cutoff,
+// along with their image files.
+func (app *application) purgeDeleted(before time.Time) (int64, error) {
+	purged, images, err := app.models.Movies.PurgeDeleted(before)
+	if err != nil {
+		return 0, err
+	}
+	app.deleteImageBlobs(images...)
+	return purged, nil
+}
@@ -50,7 +50,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = app.models.Permission.AddForUser(user.ID, "movies:read")
+	err = app.models.Permission.AddForUser(user.ID, "movies:read", "people:read")
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
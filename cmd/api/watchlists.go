@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"cinlim.bikraj.net/internal/data"
+	"cinlim.bikraj.net/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// readMovieIDParam reads the :movie_id segment of the watchlist routes.
+func (app *application) readMovieIDParam(r *http.Request) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.ParseInt(params.ByName("movie_id"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid movie_id parameter")
+	}
+	return id, nil
+}
+
+func (app *application) listWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filter
+	}
+	v := validator.New()
+
+	qs := r.URL.Query()
+	input.Filter.Page = app.readInt(qs, "page", 1, v)
+	input.Filter.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filter.Sort = app.readString(qs, "sort", "position")
+	input.Filter.SortSafeList = []string{"position", "priority", "added_at", "title", "year", "-position", "-priority", "-added_at", "-title", "-year"}
+
+	if data.ValidateFilters(v, input.Filter); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	entries, metadata, err := app.models.Watchlists.GetAllForUser(app.contextGetUser(r).ID, input.Filter)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	for _, entry := range entries {
+		app.setRuntimeFormat(r, entry.Movie)
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"metadata": metadata, "watchlist": entries}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) addWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		MovieID  int64  `json:"movie_id"`
+		Priority *int32 `json:"priority"`
+		Note     string `json:"note"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	entry := &data.WatchlistEntry{MovieID: input.MovieID, Priority: 3, Note: input.Note}
+	if input.Priority != nil {
+		entry.Priority = *input.Priority
+	}
+	v := validator.New()
+	if data.ValidateWatchlistEntry(v, entry); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	exists, err := app.movieExists(entry.MovieID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !exists {
+		v.AddError("movie_id", "no movie exists with this id")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Watchlists.Add(app.contextGetUser(r).ID, entry)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateWatchlistEntry):
+			v.AddError("movie_id", "movie is already on the watchlist")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusCreated, envelope{"watchlist_entry": entry}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	movieID, err := app.readMovieIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	user := app.contextGetUser(r)
+	entry, err := app.models.Watchlists.Get(user.ID, movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Priority *int32  `json:"priority"`
+		Note     *string `json:"note"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if input.Priority != nil {
+		entry.Priority = *input.Priority
+	}
+	if input.Note != nil {
+		entry.Note = *input.Note
+	}
+
+	v := validator.New()
+	if data.ValidateWatchlistEntry(v, entry); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Watchlists.Update(user.ID, entry)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"watchlist_entry": entry}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) removeWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	movieID, err := app.readMovieIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	err = app.models.Watchlists.Remove(app.contextGetUser(r).ID, movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "Movie Removed From Watchlist"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) reorderWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		MovieIDs []int64 `json:"movie_ids"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	v.Check(len(input.MovieIDs) > 0, "movie_ids", "must contain at least one movie")
+	v.Check(len(input.MovieIDs) <= 1000, "movie_ids", "must not contain more than 1000 movies")
+	v.Check(validator.Unique(input.MovieIDs), "movie_ids", "must be unique")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Watchlists.Reorder(app.contextGetUser(r).ID, input.MovieIDs)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.listWatchlistHandler(w, r)
+}
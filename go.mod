@@ -5,7 +5,7 @@ go 1.21.5
 require github.com/julienschmidt/httprouter v1.3.0 // direct
 
 require (
-	github.com/felixge/httpsnoop v1.0.1
+	github.com/felixge/httpsnoop v1.0.4
 	github.com/go-mail/mail/v2 v2.3.0
 	github.com/joho/godotenv v1.5.1
 	github.com/justinas/alice v1.2.0
@@ -0,0 +1,120 @@
+// Package blob stores uploaded files such as movie images behind a small
+// interface so the backing store can be swapped without touching handlers.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Store is a flat namespace of blobs addressed by slash separated keys.
+type Store interface {
+	// Put writes the contents of r under key, replacing any existing blob.
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Open returns a reader for the blob under key, or ErrNotFound.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob under key. Deleting a missing blob is not an
+	// error.
+	Delete(ctx context.Context, key string) error
+	// URL is the address clients fetch the blob from.
+	URL(key string) string
+}
+
+// Local keeps blobs as files under Dir and hands out URLs under BaseURL,
+// which the API serves itself.
+type Local struct {
+	Dir     string
+	BaseURL string
+}
+
+func NewLocal(dir, baseURL string) *Local {
+	return &Local{Dir: dir, BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// path maps key to a file under Dir, refusing keys that would escape it.
+func (s *Local) path(key string) (string, error) {
+	p := filepath.FromSlash(key)
+	if key == "" || !filepath.IsLocal(p) {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.Dir, p), nil
+}
+
+// Put writes to a temporary file first so a reader never sees a partial
+// blob.
+func (s *Local) Put(ctx context.Context, key string, r io.Reader) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(p), 0o755)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+func (s *Local) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	info, err := f.Stat()
+	if err == nil && info.IsDir() {
+		err = ErrNotFound
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *Local) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *Local) URL(key string) string {
+	return s.BaseURL + "/" + key
+}
@@ -0,0 +1,369 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"cinlim.bikraj.net/internal/validator"
+	"github.com/lib/pq"
+)
+
+var ErrDuplicateCollectionMovie = errors.New("movie already in collection")
+
+// Collection is a named, ordered group of movies such as a trilogy.
+type Collection struct {
+	ID          int64              `json:"id"`
+	CreatedAt   time.Time          `json:"created_at"`
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Movies      []*CollectionMovie `json:"movies,omitempty"`
+	Version     int32              `json:"version"`
+}
+
+// CollectionMovie is one member of a collection. Position starts at 1.
+type CollectionMovie struct {
+	ID       int64  `json:"id"`
+	Title    string `json:"title"`
+	Year     int32  `json:"year,omitempty"`
+	Position int32  `json:"position"`
+}
+
+func ValidateCollection(v *validator.Validator, collection *Collection) {
+	v.Check(collection.Name != "", "name", "must be provided")
+	v.Check(len(collection.Name) <= 500, "name", "must not be more than 500 bytes long")
+	v.Check(len(collection.Description) <= 10_000, "description", "must not be more than 10000 bytes long")
+}
+
+type CollectionModel struct {
+	DB *sql.DB
+}
+
+func (m CollectionModel) Insert(collection *Collection) error {
+	query := `
+  INSERT INTO collections (name,description)
+  VALUES ($1,$2)
+  RETURNING id,created_at,version
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, collection.Name, collection.Description).Scan(&collection.ID, &collection.CreatedAt, &collection.Version)
+}
+
+// Get returns a collection with its movies in order.
+func (m CollectionModel) Get(id int64) (*Collection, error) {
+	if id < 1 {
+		return nil, ErrNoRecordFound
+	}
+	query := `
+  SELECT id,created_at,name,description,version
+  FROM collections
+  WHERE id = $1
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var collection Collection
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&collection.ID, &collection.CreatedAt, &collection.Name, &collection.Description, &collection.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	collections := []*Collection{&collection}
+	err = m.loadMovies(ctx, collections)
+	if err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+func (m CollectionModel) GetAll(name string, filters Filter) ([]*Collection, PageMetaData, error) {
+	query := fmt.Sprintf(`
+  SELECT count(*) OVER(),id,created_at,name,description,version
+  FROM collections
+  WHERE (name ILIKE '%%' || $1 || '%%' OR $1 = '')
+  ORDER BY %s %s, id ASC
+  LIMIT $2 OFFSET $3
+  `, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, PageMetaData{}, err
+	}
+	defer rows.Close()
+
+	collections := []*Collection{}
+	var totalRecords int
+	for rows.Next() {
+		var collection Collection
+		err := rows.Scan(&totalRecords, &collection.ID, &collection.CreatedAt, &collection.Name, &collection.Description, &collection.Version)
+		if err != nil {
+			return nil, PageMetaData{}, err
+		}
+		collections = append(collections, &collection)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, PageMetaData{}, err
+	}
+	return collections, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// GetForMovie lists the collections a movie belongs to, each with all of
+// its movies in order. A movie in the trash belongs to none.
+func (m CollectionModel) GetForMovie(movieID int64) ([]*Collection, error) {
+	query := `
+  SELECT c.id,c.created_at,c.name,c.description,c.version
+  FROM collections c
+  INNER JOIN collection_movies cm ON cm.collection_id = c.id
+  INNER JOIN movies m ON m.id = cm.movie_id
+  WHERE cm.movie_id = $1 AND m.deleted_at IS NULL
+  ORDER BY c.name, c.id
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []*Collection{}
+	for rows.Next() {
+		var collection Collection
+		err := rows.Scan(&collection.ID, &collection.CreatedAt, &collection.Name, &collection.Description, &collection.Version)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, &collection)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	err = m.loadMovies(ctx, collections)
+	if err != nil {
+		return nil, err
+	}
+	return collections, nil
+}
+
+// loadMovies fills in Movies on each collection with one query. Movies in
+// the trash are left out.
+func (m CollectionModel) loadMovies(ctx context.Context, collections []*Collection) error {
+	if len(collections) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(collections))
+	byID := make(map[int64]*Collection, len(collections))
+	for i, collection := range collections {
+		ids[i] = collection.ID
+		byID[collection.ID] = collection
+		collection.Movies = []*CollectionMovie{}
+	}
+	query := `
+  SELECT cm.collection_id,m.id,m.title,m.year,cm.position
+  FROM collection_movies cm
+  INNER JOIN movies m ON m.id = cm.movie_id
+  WHERE cm.collection_id = ANY($1) AND m.deleted_at IS NULL
+  ORDER BY cm.collection_id, cm.position
+  `
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var collectionID int64
+		var movie CollectionMovie
+		err := rows.Scan(&collectionID, &movie.ID, &movie.Title, &movie.Year, &movie.Position)
+		if err != nil {
+			return err
+		}
+		byID[collectionID].Movies = append(byID[collectionID].Movies, &movie)
+	}
+	return rows.Err()
+}
+
+func (m CollectionModel) Update(collection *Collection) error {
+	query := `
+  UPDATE collections
+  SET name = $1, description = $2, version = version + 1
+  WHERE id = $3 AND version = $4
+  RETURNING version
+  `
+	args := []any{collection.Name, collection.Description, collection.ID, collection.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&collection.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes a collection. Its movies are left alone.
+func (m CollectionModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrNoRecordFound
+	}
+	query := `
+  DELETE FROM collections WHERE id = $1
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+	return nil
+}
+
+// AddMovie puts a movie at the end of a collection. The collection row is
+// locked first so concurrent adds cannot both take the same position.
+func (m CollectionModel) AddMovie(collectionID, movieID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = lockCollection(ctx, tx, collectionID)
+	if err != nil {
+		return err
+	}
+	query := `
+  INSERT INTO collection_movies (collection_id,movie_id,position)
+  SELECT $1,$2,COALESCE(MAX(position), 0) + 1
+  FROM collection_movies
+  WHERE collection_id = $1
+  HAVING EXISTS (SELECT 1 FROM movies WHERE id = $2 AND deleted_at IS NULL)
+  `
+	result, err := tx.ExecContext(ctx, query, collectionID, movieID)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateCollectionMovie
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrNoRecordFound
+		default:
+			return err
+		}
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+	return tx.Commit()
+}
+
+// lockCollection locks a collection's row for the rest of tx, serializing
+// writes to its positions.
+func lockCollection(ctx context.Context, tx *sql.Tx, id int64) error {
+	err := tx.QueryRowContext(ctx, `SELECT FROM collections WHERE id = $1 FOR UPDATE`, id).Scan()
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNoRecordFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveMovie takes a movie out of a collection. A movie in the trash is
+// not found, as it is not listed in the collection either.
+func (m CollectionModel) RemoveMovie(collectionID, movieID int64) error {
+	query := `
+  DELETE FROM collection_movies
+  WHERE collection_id = $1 AND movie_id = $2
+  AND EXISTS (SELECT 1 FROM movies WHERE id = $2 AND deleted_at IS NULL)
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, collectionID, movieID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+	return nil
+}
+
+// Reorder moves the given movies to the front of the collection in the order
+// listed. Movies that are not mentioned keep their relative order after them.
+// Movies in the trash are ranked as if they were not mentioned, so they
+// cannot be moved while hidden but still hold a position for Restore.
+func (m CollectionModel) Reorder(collectionID int64, movieIDs []int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = lockCollection(ctx, tx, collectionID)
+	if err != nil {
+		return err
+	}
+	query := `
+  UPDATE collection_movies c
+  SET position = ranked.position
+  FROM (
+    SELECT e.movie_id, row_number() OVER (
+      ORDER BY CASE WHEN m.deleted_at IS NULL THEN o.ord END NULLS LAST, e.position
+    ) AS position
+    FROM collection_movies e
+    INNER JOIN movies m ON m.id = e.movie_id
+    LEFT JOIN unnest($2::bigint[]) WITH ORDINALITY AS o(movie_id, ord) ON o.movie_id = e.movie_id
+    WHERE e.collection_id = $1
+  ) AS ranked
+  WHERE c.collection_id = $1 AND c.movie_id = ranked.movie_id
+  `
+	_, err = tx.ExecContext(ctx, query, collectionID, pq.Array(movieIDs))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,135 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"cinlim.bikraj.net/internal/validator"
+	"github.com/lib/pq"
+)
+
+var CreditRoles = []string{"director", "writer", "actor"}
+
+var ErrDuplicateCredit = errors.New("duplicate credit")
+
+// Credit links a person to a movie in a role. Character is only meaningful
+// for actors.
+type Credit struct {
+	ID         int64  `json:"id"`
+	MovieID    int64  `json:"movie_id"`
+	PersonID   int64  `json:"person_id"`
+	PersonName string `json:"person_name,omitempty"`
+	MovieTitle string `json:"movie_title,omitempty"`
+	Role       string `json:"role"`
+	Character  string `json:"character,omitempty"`
+}
+
+func ValidateCredit(v *validator.Validator, credit *Credit) {
+	v.Check(credit.PersonID > 0, "person_id", "must be provided")
+	v.Check(validator.In(credit.Role, CreditRoles...), "role", "must be one of director, writer or actor")
+	v.Check(credit.Character == "" || credit.Role == "actor", "character", "can only be set for actors")
+	v.Check(len(credit.Character) <= 500, "character", "must not be more than 500 bytes long")
+}
+
+type CreditModel struct {
+	DB *sql.DB
+}
+
+func (m CreditModel) Insert(credit *Credit) error {
+	query := `
+  INSERT INTO credits (movie_id,person_id,role,character_name)
+  VALUES ($1,$2,$3,$4)
+  RETURNING id
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, credit.MovieID, credit.PersonID, credit.Role, credit.Character).Scan(&credit.ID)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateCredit
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrNoRecordFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m CreditModel) Delete(movieID, creditID int64) error {
+	query := `
+  DELETE FROM credits WHERE movie_id = $1 AND id = $2
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, creditID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+	return nil
+}
+
+// creditSelect joins each credit to its person and movie. Credits on movies
+// in the trash are left out.
+const creditSelect = `
+  SELECT c.id,c.movie_id,c.person_id,p.name,mv.title,c.role,c.character_name
+  FROM credits c
+  INNER JOIN people p ON p.id = c.person_id
+  INNER JOIN movies mv ON mv.id = c.movie_id
+  WHERE mv.deleted_at IS NULL`
+
+// GetForMovie lists a movie's credits, directors and writers before cast.
+func (m CreditModel) GetForMovie(movieID int64) ([]*Credit, error) {
+	query := creditSelect + `
+  AND c.movie_id = $1
+  ORDER BY array_position(ARRAY['director','writer','actor'], c.role), c.id
+  `
+	return m.query(query, movieID)
+}
+
+// GetForPerson lists a person's credits, newest movie first.
+func (m CreditModel) GetForPerson(personID int64) ([]*Credit, error) {
+	query := creditSelect + `
+  AND c.person_id = $1
+  ORDER BY mv.year DESC, c.id
+  `
+	return m.query(query, personID)
+}
+
+func (m CreditModel) query(query string, args ...any) ([]*Credit, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credits := []*Credit{}
+	for rows.Next() {
+		var credit Credit
+		err := rows.Scan(&credit.ID, &credit.MovieID, &credit.PersonID, &credit.PersonName, &credit.MovieTitle, &credit.Role, &credit.Character)
+		if err != nil {
+			return nil, err
+		}
+		credits = append(credits, &credit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return credits, nil
+}
@@ -0,0 +1,138 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// DuplicateSimilarity is the trigram similarity at which two titles from the
+// same year are treated as the same film.
+const DuplicateSimilarity = 0.6
+
+// DuplicateCandidate is an existing movie that looks like the same film.
+type DuplicateCandidate struct {
+	ID         int64   `json:"id"`
+	Title      string  `json:"title"`
+	Year       int32   `json:"year"`
+	Similarity float32 `json:"similarity"`
+}
+
+// DuplicateCluster is a group of movies that look like copies of each other.
+type DuplicateCluster struct {
+	Movies []*DuplicateCandidate `json:"movies"`
+}
+
+// FindDuplicates returns live movies from year whose titles are similar to
+// title, best match first. pg_trgm ignores case and punctuation, so
+// "Se7en" and "se7en!" match exactly. The % operator lets the trigram index
+// narrow the rows before the stricter threshold is applied.
+func (m MovieModel) FindDuplicates(title string, year int32) ([]*DuplicateCandidate, error) {
+	query := `
+  SELECT id,title,year,similarity(title, $1) AS score
+  FROM movies
+  WHERE deleted_at IS NULL AND year = $2 AND title % $1 AND similarity(title, $1) >= $3
+  ORDER BY score DESC, id
+  LIMIT 10
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, title, year, DuplicateSimilarity)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []*DuplicateCandidate{}
+	for rows.Next() {
+		var candidate DuplicateCandidate
+		err := rows.Scan(&candidate.ID, &candidate.Title, &candidate.Year, &candidate.Similarity)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, &candidate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return candidates, nil
+}
+
+// GetDuplicateClusters groups live movies into clusters of suspected
+// duplicates. Pairs from the same year with similar titles are linked and
+// the clusters are the connected groups, so A~B and B~C put all three
+// together. Clusters are named by their lowest movie id, ordered by it and
+// paged with filters.
+//
+// The clustering runs in the database, so only one page of clusters is ever
+// held in memory. Linking every movie to every other in its cluster costs the
+// square of the cluster's size, which stays small for real duplicates; the
+// statement timeout bounds the query should a title shared by very many
+// movies make one cluster huge.
+func (m MovieModel) GetDuplicateClusters(filters Filter) ([]*DuplicateCluster, PageMetaData, error) {
+	query := `
+  WITH RECURSIVE pairs AS (
+    SELECT a.id AS a, b.id AS b, similarity(a.title, b.title) AS score
+    FROM movies a
+    INNER JOIN movies b ON b.year = a.year AND b.id > a.id AND b.title % a.title
+    WHERE a.deleted_at IS NULL AND b.deleted_at IS NULL
+    AND similarity(a.title, b.title) >= $1
+  ),
+  edges AS (
+    SELECT a, b, score FROM pairs
+    UNION ALL
+    SELECT b, a, score FROM pairs
+  ),
+  reach (id, member) AS (
+    SELECT DISTINCT a, a FROM edges
+    UNION
+    SELECT reach.id, edges.b FROM reach INNER JOIN edges ON edges.a = reach.member
+  ),
+  clusters AS (
+    SELECT id, min(member) AS root FROM reach GROUP BY id
+  ),
+  page AS (
+    SELECT count(*) OVER() AS total, root
+    FROM (SELECT DISTINCT root FROM clusters) AS roots
+    ORDER BY root
+    LIMIT $2 OFFSET $3
+  )
+  SELECT page.total, page.root, movies.id, movies.title, movies.year,
+    (SELECT max(score) FROM edges WHERE edges.a = movies.id)
+  FROM page
+  INNER JOIN clusters ON clusters.root = page.root
+  INNER JOIN movies ON movies.id = clusters.id
+  ORDER BY page.root, movies.id
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, DuplicateSimilarity, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, PageMetaData{}, err
+	}
+	defer rows.Close()
+
+	clusters := []*DuplicateCluster{}
+	var totalRecords int
+	var current int64
+	for rows.Next() {
+		var root int64
+		var movie DuplicateCandidate
+		// Each movie reports its best match within the cluster.
+		err := rows.Scan(&totalRecords, &root, &movie.ID, &movie.Title, &movie.Year, &movie.Similarity)
+		if err != nil {
+			return nil, PageMetaData{}, err
+		}
+		if len(clusters) == 0 || root != current {
+			clusters = append(clusters, &DuplicateCluster{})
+			current = root
+		}
+		cluster := clusters[len(clusters)-1]
+		cluster.Movies = append(cluster.Movies, &movie)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, PageMetaData{}, err
+	}
+	return clusters, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
@@ -0,0 +1,195 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"cinlim.bikraj.net/internal/validator"
+	"github.com/lib/pq"
+)
+
+// ExternalNamespaceRX matches a catalogue name such as "imdb" or "tmdb".
+var ExternalNamespaceRX = regexp.MustCompile(`^[a-z][a-z0-9_.-]{0,49}$`)
+
+var ErrDuplicateExternalID = errors.New("external id already belongs to another movie, which may be in the trash")
+
+func ValidateExternalIDs(v *validator.Validator, ids map[string]string) {
+	v.Check(len(ids) <= 20, "external_ids", "must not contain more than 20 ids")
+	for namespace, value := range ids {
+		ValidateExternalID(v, namespace, value)
+	}
+}
+
+func ValidateExternalID(v *validator.Validator, namespace, value string) {
+	v.Check(validator.Matches(namespace, ExternalNamespaceRX), "external_ids", fmt.Sprintf("namespace %q must be lowercase letters, digits, '.', '_' or '-'", namespace))
+	v.Check(value != "", "external_ids", fmt.Sprintf("value for %q must be provided", namespace))
+	v.Check(len(value) <= 200, "external_ids", fmt.Sprintf("value for %q must not be more than 200 bytes long", namespace))
+}
+
+// saveExternalIDs sets a movie's value in each namespace of ids, leaving
+// its other namespaces alone.
+func saveExternalIDs(ctx context.Context, tx *sql.Tx, movieID int64, ids map[string]string) error {
+	query := `
+  INSERT INTO movie_external_ids (movie_id,namespace,value)
+  VALUES ($1,$2,$3)
+  ON CONFLICT (movie_id, namespace) DO UPDATE SET value = EXCLUDED.value
+  `
+	for namespace, value := range ids {
+		_, err := tx.ExecContext(ctx, query, movieID, namespace, value)
+		if err != nil {
+			var pqErr *pq.Error
+			switch {
+			case errors.As(err, &pqErr) && pqErr.Code == "23505":
+				return ErrDuplicateExternalID
+			default:
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type ExternalIDModel struct {
+	DB *sql.DB
+}
+
+// Put sets a movie's value in one namespace.
+func (m ExternalIDModel) Put(movieID int64, namespace, value string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = saveExternalIDs(ctx, tx, movieID, map[string]string{namespace: value})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m ExternalIDModel) Delete(movieID int64, namespace string) error {
+	query := `
+  DELETE FROM movie_external_ids
+  WHERE movie_id = $1 AND namespace = $2
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, namespace)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+	return nil
+}
+
+// GetForMovie returns a movie's external ids keyed by namespace.
+func (m ExternalIDModel) GetForMovie(movieID int64) (map[string]string, error) {
+	query := `
+  SELECT namespace,value
+  FROM movie_external_ids
+  WHERE movie_id = $1
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := map[string]string{}
+	for rows.Next() {
+		var namespace, value string
+		err := rows.Scan(&namespace, &value)
+		if err != nil {
+			return nil, err
+		}
+		ids[namespace] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// GetMovieID finds the movie holding value in namespace. Movies in the
+// trash are not found.
+func (m ExternalIDModel) GetMovieID(namespace, value string) (int64, error) {
+	query := `
+  SELECT e.movie_id
+  FROM movie_external_ids e
+  INNER JOIN movies m ON m.id = e.movie_id
+  WHERE e.namespace = $1 AND e.value = $2 AND m.deleted_at IS NULL
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var id int64
+	err := m.DB.QueryRowContext(ctx, query, namespace, value).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNoRecordFound
+		default:
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+// FindMovies returns the distinct movies holding any of ids, in id order.
+func (m ExternalIDModel) FindMovies(ids map[string]string) ([]int64, error) {
+	namespaces := make([]string, 0, len(ids))
+	values := make([]string, 0, len(ids))
+	for namespace, value := range ids {
+		namespaces = append(namespaces, namespace)
+		values = append(values, value)
+	}
+	query := `
+  SELECT DISTINCT e.movie_id
+  FROM movie_external_ids e
+  INNER JOIN unnest($1::text[], $2::text[]) AS wanted(namespace, value)
+    ON wanted.namespace = e.namespace AND wanted.value = e.value
+  INNER JOIN movies m ON m.id = e.movie_id
+  WHERE m.deleted_at IS NULL
+  ORDER BY e.movie_id
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(namespaces), pq.Array(values))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movieIDs := []int64{}
+	for rows.Next() {
+		var id int64
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		movieIDs = append(movieIDs, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return movieIDs, nil
+}
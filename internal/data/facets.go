@@ -0,0 +1,86 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MovieFacets lists the facets a movie listing can be aggregated by.
+var MovieFacets = []string{"genres", "decade", "runtime_bucket"}
+
+// FacetCount is the number of matching movies sharing one facet value.
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// movieFacetQueries maps each facet to a query over the matched CTE that
+// yields (value, count, position) rows. Runtime bands are fixed so the UI
+// can show stable labels.
+var movieFacetQueries = map[string]string{
+	"genres": `
+    SELECT g, count(*), 0 FROM matched, unnest(genres) AS g GROUP BY g`,
+	"decade": `
+    SELECT (year / 10 * 10)::text || 's', count(*), year / 10 * 10 FROM matched GROUP BY year / 10 * 10`,
+	"runtime_bucket": `
+    SELECT band, count(*), min(runtime) FROM (
+      SELECT runtime, CASE
+        WHEN runtime < 90 THEN 'under 90 mins'
+        WHEN runtime < 120 THEN '90-119 mins'
+        WHEN runtime < 150 THEN '120-149 mins'
+        ELSE '150+ mins'
+      END AS band
+      FROM matched
+    ) AS bands GROUP BY band`,
+}
+
+// Facets counts the movies matching q by each of the named facets. Counts
+// are taken over the whole filtered set, not a page of it. Genres are
+// ordered by count, decades and runtime bands by their lower bound.
+func (m MovieModel) Facets(q MovieQuery, facets []string) (map[string][]FacetCount, error) {
+	result := make(map[string][]FacetCount, len(facets))
+	if len(facets) == 0 {
+		return result, nil
+	}
+
+	parts := make([]string, len(facets))
+	for i, facet := range facets {
+		parts[i] = fmt.Sprintf(`
+  SELECT '%s' AS facet, * FROM (%s) AS f%d(value, count, position)`, facet, movieFacetQueries[facet], i)
+		result[facet] = []FacetCount{}
+	}
+	where, args := q.where()
+	query := fmt.Sprintf(`
+  WITH matched AS (
+    SELECT genres, year, runtime FROM movies WHERE %s
+  )
+  SELECT facet, value, count FROM (%s
+  ) AS facets
+  ORDER BY facet, CASE WHEN facet = 'genres' THEN -count ELSE position END, value
+  `, where, strings.Join(parts, "\n  UNION ALL"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var facet string
+		var count FacetCount
+		err := rows.Scan(&facet, &count.Value, &count.Count)
+		if err != nil {
+			return nil, err
+		}
+		result[facet] = append(result[facet], count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -1,6 +1,9 @@
 package data
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"math"
 	"strings"
 
@@ -12,14 +15,49 @@ type Filter struct {
 	PageSize     int
 	Sort         string
 	SortSafeList []string
+	// Cursor is an opaque keyset token taken from a previous next_cursor or
+	// prev_cursor. When it is set Page is ignored.
+	Cursor string
+	// IncludeTotal asks for the count(*) OVER() window to be computed.
+	IncludeTotal bool
 }
 
 type PageMetaData struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	PrevCursor   string `json:"prev_cursor,omitempty"`
+}
+
+// cursor is the decoded form of a keyset token. It holds the sort it was
+// issued for, the sort key and id of the boundary row, and whether it pages
+// backwards from that row.
+type cursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	ID   int64  `json:"i"`
+	Prev bool   `json:"p,omitempty"`
+}
+
+func encodeCursor(c cursor) string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c cursor
+	err = json.Unmarshal(js, &c)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
 }
 
 func ValidateFilters(v *validator.Validator, f Filter) {
@@ -30,6 +68,16 @@ func ValidateFilters(v *validator.Validator, f Filter) {
 
 	// Check if the sort paramter is in the SortSafeList
 	v.Check(validator.In(f.Sort, f.SortSafeList...), "sort", "Invalid sort value")
+
+	if f.Cursor != "" {
+		v.Check(f.Page == 1, "cursor", "cannot be combined with page")
+		c, err := decodeCursor(f.Cursor)
+		if err != nil {
+			v.AddError("cursor", "is not a valid cursor")
+			return
+		}
+		v.Check(c.Sort == f.Sort, "cursor", "was issued for a different sort")
+	}
 }
 
 func (f Filter) sortColumn() string {
@@ -41,6 +89,13 @@ func (f Filter) sortColumn() string {
 	panic("unsafe sort parameter: " + f.Sort)
 }
 func (f Filter) sortDirection() string {
+	// Relevance reads best match first, so its keys sort the other way round.
+	if f.Sort == "relevance" {
+		return "DESC"
+	}
+	if f.Sort == "-relevance" {
+		return "ASC"
+	}
 	if strings.HasPrefix(f.Sort, "-") {
 		return "DESC"
 	} else {
@@ -51,8 +106,64 @@ func (f Filter) limit() int {
 	return f.PageSize
 }
 func (f Filter) offset() int {
-	return (f.PageSize - 1) * f.Page
+	if f.Cursor != "" {
+		return 0
+	}
+	return (f.Page - 1) * f.PageSize
 }
+
+// cursor returns the decoded keyset token, or nil when paging by offset. The
+// filter must have passed ValidateFilters first.
+func (f Filter) cursor() *cursor {
+	if f.Cursor == "" {
+		return nil
+	}
+	c, err := decodeCursor(f.Cursor)
+	if err != nil {
+		panic("unvalidated cursor: " + f.Cursor)
+	}
+	return c
+}
+
+// keyset returns the WHERE condition and ORDER BY clause for the page. key and
+// id are the placeholders holding the cursor values. Paging backwards flips
+// both the comparison and the order, so callers must reverse the rows.
+func (f Filter) keyset(key, id string) (string, string) {
+	column, direction := f.sortColumn(), f.sortDirection()
+	op, idOp, idDirection := ">", ">", "ASC"
+	if direction == "DESC" {
+		op = "<"
+	}
+	c := f.cursor()
+	if c != nil && c.Prev {
+		if op == ">" {
+			op, direction = "<", "DESC"
+		} else {
+			op, direction = ">", "ASC"
+		}
+		idOp, idDirection = "<", "DESC"
+	}
+	orderBy := fmt.Sprintf("%s %s, id %s", column, direction, idDirection)
+	if c == nil {
+		return "TRUE", orderBy
+	}
+	where := fmt.Sprintf("(%s %s %s OR (%s = %s AND id %s %s))", column, op, key, column, key, idOp, id)
+	return where, orderBy
+}
+
+// pageCursors fills in next_cursor and prev_cursor from the boundary rows of a
+// page. hasMore reports whether a row beyond the page was fetched.
+func (f Filter) pageCursors(metadata *PageMetaData, firstKey string, firstID int64, lastKey string, lastID int64, hasMore bool) {
+	c := f.cursor()
+	backwards := c != nil && c.Prev
+	if hasMore || backwards {
+		metadata.NextCursor = encodeCursor(cursor{Sort: f.Sort, Key: lastKey, ID: lastID})
+	}
+	if (backwards && hasMore) || (c != nil && !backwards) || (c == nil && f.Page > 1) {
+		metadata.PrevCursor = encodeCursor(cursor{Sort: f.Sort, Key: firstKey, ID: firstID, Prev: true})
+	}
+}
+
 func calculateMetadata(totalRecords, page, page_size int) PageMetaData {
 	if totalRecords == 0 {
 		return PageMetaData{}
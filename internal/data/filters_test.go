@@ -0,0 +1,139 @@
+package data
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"cinlim.bikraj.net/internal/validator"
+)
+
+var testSortSafeList = []string{"id", "title", "relevance", "-id", "-title", "-relevance"}
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []cursor{
+		{Sort: "id", Key: "42", ID: 42},
+		{Sort: "-title", Key: "Héros / \"quoted\"", ID: 7, Prev: true},
+		{Sort: "relevance", Key: "0.0607927", ID: 1},
+	}
+	for _, want := range tests {
+		got, err := decodeCursor(encodeCursor(want))
+		if err != nil {
+			t.Fatalf("decodeCursor(encodeCursor(%+v)): %v", want, err)
+		}
+		if *got != want {
+			t.Errorf("decodeCursor(encodeCursor(%+v)) = %+v", want, *got)
+		}
+	}
+}
+
+func TestValidateFiltersCursor(t *testing.T) {
+	idCursor := encodeCursor(cursor{Sort: "id", Key: "10", ID: 10})
+	tests := []struct {
+		name    string
+		filter  Filter
+		wantErr string
+	}{
+		{name: "no cursor", filter: Filter{Sort: "id"}},
+		{name: "cursor for the same sort", filter: Filter{Sort: "id", Cursor: idCursor}},
+		{name: "cursor for another sort", filter: Filter{Sort: "-id", Cursor: idCursor}, wantErr: "was issued for a different sort"},
+		{name: "cursor with a page", filter: Filter{Sort: "id", Cursor: idCursor, Page: 2}, wantErr: "cannot be combined with page"},
+		{name: "not base64", filter: Filter{Sort: "id", Cursor: "not a cursor!"}, wantErr: "is not a valid cursor"},
+		{name: "not JSON", filter: Filter{Sort: "id", Cursor: base64.RawURLEncoding.EncodeToString([]byte("id:10"))}, wantErr: "is not a valid cursor"},
+		{name: "wrong field types", filter: Filter{Sort: "id", Cursor: base64.RawURLEncoding.EncodeToString([]byte(`{"s":"id","k":"10","i":"10"}`))}, wantErr: "is not a valid cursor"},
+		{name: "padded base64", filter: Filter{Sort: "id", Cursor: idCursor + "="}, wantErr: "is not a valid cursor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tt.filter
+			if f.Page == 0 {
+				f.Page = 1
+			}
+			f.PageSize = 20
+			f.SortSafeList = testSortSafeList
+
+			v := validator.New()
+			ValidateFilters(v, f)
+			if got := v.Errors["cursor"]; got != tt.wantErr {
+				t.Errorf("cursor error = %q, want %q", got, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyset(t *testing.T) {
+	tests := []struct {
+		sort        string
+		prev        bool
+		noCursor    bool
+		wantWhere   string
+		wantOrderBy string
+	}{
+		{sort: "id", noCursor: true, wantWhere: "TRUE", wantOrderBy: "id ASC, id ASC"},
+		{sort: "title", wantWhere: "(title > $1 OR (title = $1 AND id > $2))", wantOrderBy: "title ASC, id ASC"},
+		{sort: "-title", wantWhere: "(title < $1 OR (title = $1 AND id > $2))", wantOrderBy: "title DESC, id ASC"},
+		{sort: "title", prev: true, wantWhere: "(title < $1 OR (title = $1 AND id < $2))", wantOrderBy: "title DESC, id DESC"},
+		{sort: "-title", prev: true, wantWhere: "(title > $1 OR (title = $1 AND id < $2))", wantOrderBy: "title ASC, id DESC"},
+		{sort: "relevance", wantWhere: "(relevance < $1 OR (relevance = $1 AND id > $2))", wantOrderBy: "relevance DESC, id ASC"},
+		{sort: "-relevance", wantWhere: "(relevance > $1 OR (relevance = $1 AND id > $2))", wantOrderBy: "relevance ASC, id ASC"},
+		{sort: "relevance", prev: true, wantWhere: "(relevance > $1 OR (relevance = $1 AND id < $2))", wantOrderBy: "relevance ASC, id DESC"},
+	}
+	for _, tt := range tests {
+		f := Filter{Sort: tt.sort, SortSafeList: testSortSafeList}
+		if !tt.noCursor {
+			f.Cursor = encodeCursor(cursor{Sort: tt.sort, Key: "k", ID: 1, Prev: tt.prev})
+		}
+		where, orderBy := f.keyset("$1", "$2")
+		if where != tt.wantWhere || orderBy != tt.wantOrderBy {
+			t.Errorf("keyset for sort %q, prev %t = %q, %q; want %q, %q", tt.sort, tt.prev, where, orderBy, tt.wantWhere, tt.wantOrderBy)
+		}
+	}
+}
+
+func TestPageCursors(t *testing.T) {
+	tests := []struct {
+		name     string
+		page     int
+		cursor   *cursor
+		hasMore  bool
+		wantNext bool
+		wantPrev bool
+	}{
+		{name: "only page", page: 1},
+		{name: "first of several pages", page: 1, hasMore: true, wantNext: true},
+		{name: "offset page after the first", page: 3, wantPrev: true},
+		{name: "offset page in the middle", page: 3, hasMore: true, wantNext: true, wantPrev: true},
+		{name: "forwards to the last page", page: 1, cursor: &cursor{Sort: "id", Key: "5", ID: 5}, wantPrev: true},
+		{name: "forwards to a middle page", page: 1, cursor: &cursor{Sort: "id", Key: "5", ID: 5}, hasMore: true, wantNext: true, wantPrev: true},
+		{name: "backwards to the first page", page: 1, cursor: &cursor{Sort: "id", Key: "5", ID: 5, Prev: true}, wantNext: true},
+		{name: "backwards to a middle page", page: 1, cursor: &cursor{Sort: "id", Key: "5", ID: 5, Prev: true}, hasMore: true, wantNext: true, wantPrev: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Filter{Sort: "id", Page: tt.page, SortSafeList: testSortSafeList}
+			if tt.cursor != nil {
+				f.Cursor = encodeCursor(*tt.cursor)
+			}
+			var metadata PageMetaData
+			f.pageCursors(&metadata, "2", 2, "4", 4, tt.hasMore)
+
+			if (metadata.NextCursor != "") != tt.wantNext {
+				t.Fatalf("next_cursor = %q, want one: %t", metadata.NextCursor, tt.wantNext)
+			}
+			if (metadata.PrevCursor != "") != tt.wantPrev {
+				t.Fatalf("prev_cursor = %q, want one: %t", metadata.PrevCursor, tt.wantPrev)
+			}
+			if tt.wantNext {
+				next, _ := decodeCursor(metadata.NextCursor)
+				if want := (cursor{Sort: "id", Key: "4", ID: 4}); *next != want {
+					t.Errorf("next_cursor = %+v, want %+v", *next, want)
+				}
+			}
+			if tt.wantPrev {
+				prev, _ := decodeCursor(metadata.PrevCursor)
+				if want := (cursor{Sort: "id", Key: "2", ID: 2, Prev: true}); *prev != want {
+					t.Errorf("prev_cursor = %+v, want %+v", *prev, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,407 @@
+package data
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"cinlim.bikraj.net/internal/validator"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateGenre = errors.New("duplicate genre")
+	ErrGenreInUse     = errors.New("genre in use")
+)
+
+var genreKeyRX = regexp.MustCompile(`[^a-z0-9]+`)
+
+// GenreKey folds a genre spelling to the form slugs and aliases are stored
+// in, so "Sci-Fi", "sci fi" and "SCI_FI" all become "sci-fi". A spelling
+// with letters or digits outside a-z and 0-9 only, such as "ドラマ", folds to
+// "genre-" and the start of its MD5 instead. The 000013 migration applies the
+// same folding in SQL.
+func GenreKey(s string) string {
+	key := strings.Trim(genreKeyRX.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if key == "" && strings.IndexFunc(s, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0 {
+		sum := md5.Sum([]byte(s))
+		key = "genre-" + hex.EncodeToString(sum[:])[:8]
+	}
+	return key
+}
+
+// Genre is an entry in the managed genre catalogue. Movies store the slug.
+type Genre struct {
+	ID         int64    `json:"id"`
+	Slug       string   `json:"slug"`
+	Name       string   `json:"name"`
+	Aliases    []string `json:"aliases"`
+	MovieCount int64    `json:"movie_count"`
+	Version    int32    `json:"version"`
+}
+
+func ValidateGenre(v *validator.Validator, genre *Genre) {
+	v.Check(genre.Name != "", "name", "must be provided")
+	v.Check(len(genre.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(genre.Slug != "", "slug", "must contain at least one letter or digit")
+	v.Check(genre.Slug == GenreKey(genre.Slug), "slug", "must only contain lowercase letters, digits and dashes")
+	for _, alias := range genre.Aliases {
+		v.Check(GenreKey(alias) != "", "aliases", "must contain at least one letter or digit")
+	}
+}
+
+type GenreModel struct {
+	DB *sql.DB
+}
+
+// Insert adds a genre. Its slug and every alias are registered as lookup keys.
+func (m GenreModel) Insert(genre *Genre) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+  INSERT INTO genres (slug,name)
+  VALUES ($1,$2)
+  RETURNING id,version
+  `
+	err = tx.QueryRowContext(ctx, query, genre.Slug, genre.Name).Scan(&genre.ID, &genre.Version)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateGenre
+		default:
+			return err
+		}
+	}
+	for _, alias := range append([]string{genre.Slug}, genre.Aliases...) {
+		err = addGenreAlias(ctx, tx, genre.ID, alias)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func addGenreAlias(ctx context.Context, tx *sql.Tx, genreID int64, alias string) error {
+	query := `
+  INSERT INTO genre_aliases (alias,genre_id)
+  VALUES ($1,$2)
+  `
+	_, err := tx.ExecContext(ctx, query, GenreKey(alias), genreID)
+	var pqErr *pq.Error
+	switch {
+	case errors.As(err, &pqErr) && pqErr.Code == "23505":
+		return ErrDuplicateGenre
+	case errors.As(err, &pqErr) && pqErr.Code == "23503":
+		return ErrNoRecordFound
+	default:
+		return err
+	}
+}
+
+// AddAlias registers another spelling that normalises to the genre.
+func (m GenreModel) AddAlias(genreID int64, alias string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = addGenreAlias(ctx, tx, genreID, alias)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveAlias drops an alias. A genre's own slug cannot be removed.
+func (m GenreModel) RemoveAlias(genreID int64, alias string) error {
+	query := `
+  DELETE FROM genre_aliases a
+  USING genres g
+  WHERE g.id = a.genre_id AND a.genre_id = $1 AND a.alias = $2 AND a.alias <> g.slug
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, genreID, GenreKey(alias))
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+	return nil
+}
+
+const genreSelect = `
+  SELECT g.id,g.slug,g.name,g.version,
+    ARRAY(SELECT a.alias FROM genre_aliases a WHERE a.genre_id = g.id AND a.alias <> g.slug ORDER BY a.alias),
+    (SELECT count(*) FROM movies m WHERE m.genres @> ARRAY[g.slug] AND m.deleted_at IS NULL)
+  FROM genres g`
+
+func (g *Genre) fields() []any {
+	return []any{&g.ID, &g.Slug, &g.Name, &g.Version, pq.Array(&g.Aliases), &g.MovieCount}
+}
+
+func (m GenreModel) Get(id int64) (*Genre, error) {
+	query := genreSelect + `
+  WHERE g.id = $1
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var genre Genre
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(genre.fields()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	return &genre, nil
+}
+
+// GetAll lists the whole catalogue with the number of live movies in each
+// genre. The catalogue is small enough that it is not paged.
+func (m GenreModel) GetAll() ([]*Genre, error) {
+	query := genreSelect + `
+  ORDER BY g.slug
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	genres := []*Genre{}
+	for rows.Next() {
+		var genre Genre
+		err := rows.Scan(genre.fields()...)
+		if err != nil {
+			return nil, err
+		}
+		genres = append(genres, &genre)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return genres, nil
+}
+
+// Update renames a genre. The slug is fixed once created.
+func (m GenreModel) Update(genre *Genre) error {
+	query := `
+  UPDATE genres
+  SET name = $1, version = version + 1
+  WHERE id = $2 AND version = $3
+  RETURNING version
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, genre.Name, genre.ID, genre.Version).Scan(&genre.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes a genre that no movie, live or in the trash, still uses.
+func (m GenreModel) Delete(id int64) error {
+	query := `
+  DELETE FROM genres g
+  WHERE g.id = $1
+  AND NOT EXISTS (SELECT 1 FROM movies m WHERE m.genres @> ARRAY[g.slug])
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		_, err := m.Get(id)
+		if err != nil {
+			return err
+		}
+		return ErrGenreInUse
+	}
+	return nil
+}
+
+// Merge folds the genre from into the genre into: its slug and aliases become
+// aliases of into, and every movie tagged with it is retagged on behalf of
+// userID.
+func (m GenreModel) Merge(into, from int64, userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var intoSlug, fromSlug string
+	err = tx.QueryRowContext(ctx, `SELECT slug FROM genres WHERE id = $1 FOR UPDATE`, into).Scan(&intoSlug)
+	if err == nil {
+		err = tx.QueryRowContext(ctx, `SELECT slug FROM genres WHERE id = $1 FOR UPDATE`, from).Scan(&fromSlug)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNoRecordFound
+		default:
+			return err
+		}
+	}
+
+	// Swap the old slug for the new one, dropping it where the movie already
+	// has both, while keeping the original order.
+	query := `
+  UPDATE movies
+  SET genres = ARRAY(
+    SELECT g FROM (
+      SELECT CASE WHEN g = $2 THEN $1 ELSE g END AS g, min(ord) AS ord
+      FROM unnest(genres) WITH ORDINALITY AS t(g, ord)
+      GROUP BY 1
+    ) AS s ORDER BY ord
+  ), version = version + 1
+  WHERE genres @> ARRAY[$2]
+  RETURNING id
+  `
+	rows, err := tx.QueryContext(ctx, query, intoSlug, fromSlug)
+	if err != nil {
+		return err
+	}
+	var retagged []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		retagged = append(retagged, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	// Retagging is an edit like any other, so each movie gets a new version
+	// and a revision in its history.
+	for _, id := range retagged {
+		err = recordRevision(ctx, tx, id, userID, RevisionUpdate)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE genre_aliases SET genre_id = $1 WHERE genre_id = $2`, into, from)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM genres WHERE id = $1`, from)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GenreResolver maps every alias key in the catalogue to its genre's slug.
+type GenreResolver map[string]string
+
+// Resolver loads the alias table so a batch of movies can be normalised
+// without a query per movie.
+func (m GenreModel) Resolver() (GenreResolver, error) {
+	query := `
+  SELECT a.alias, g.slug
+  FROM genre_aliases a
+  INNER JOIN genres g ON g.id = a.genre_id
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resolver := make(GenreResolver)
+	for rows.Next() {
+		var alias, slug string
+		err := rows.Scan(&alias, &slug)
+		if err != nil {
+			return nil, err
+		}
+		resolver[alias] = slug
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return resolver, nil
+}
+
+// Normalize maps each spelling in names to its canonical slug, dropping any
+// repeats. Spellings that match no genre are returned in unknown.
+func (r GenreResolver) Normalize(names []string) (slugs []string, unknown []string) {
+	slugs = []string{}
+	for _, name := range names {
+		slug, ok := r[GenreKey(name)]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		if !validator.In(slug, slugs...) {
+			slugs = append(slugs, slug)
+		}
+	}
+	return slugs, unknown
+}
+
+// NormalizeMovieGenres rewrites movie.Genres to canonical slugs, recording an
+// error against genres for any spelling the catalogue does not know.
+func NormalizeMovieGenres(v *validator.Validator, r GenreResolver, movie *Movie) {
+	if movie.Genres == nil {
+		return
+	}
+	slugs, unknown := r.Normalize(movie.Genres)
+	v.Check(len(unknown) == 0, "genres", fmt.Sprintf("unknown genre %q", strings.Join(unknown, `", "`)))
+	movie.Genres = slugs
+}
@@ -0,0 +1,196 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"cinlim.bikraj.net/internal/validator"
+	"github.com/lib/pq"
+)
+
+var ImageKinds = []string{"poster", "backdrop", "still"}
+
+// MovieImage is an uploaded piece of artwork. The blob keys locate the
+// original and its thumbnail in the blob store; the URLs are filled in from
+// them by the API before a response is written.
+type MovieImage struct {
+	ID           int64     `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	MovieID      int64     `json:"movie_id"`
+	Kind         string    `json:"kind"`
+	ContentType  string    `json:"content_type"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	Size         int64     `json:"size"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
+	BlobKey      string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+}
+
+func ValidateMovieImage(v *validator.Validator, image *MovieImage) {
+	v.Check(validator.In(image.Kind, ImageKinds...), "kind", "must be one of poster, backdrop or still")
+}
+
+type MovieImageModel struct {
+	DB *sql.DB
+}
+
+func (m MovieImageModel) Insert(image *MovieImage) error {
+	query := `
+  INSERT INTO movie_images (movie_id,kind,content_type,width,height,size,blob_key)
+  VALUES ($1,$2,$3,$4,$5,$6,$7)
+  RETURNING id,created_at
+  `
+	args := []any{image.MovieID, image.Kind, image.ContentType, image.Width, image.Height, image.Size, image.BlobKey}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&image.ID, &image.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrNoRecordFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// SetThumbnail records the blob key of a generated thumbnail.
+func (m MovieImageModel) SetThumbnail(id int64, key string) error {
+	query := `
+  UPDATE movie_images SET thumbnail_key = $1 WHERE id = $2
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, key, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+	return nil
+}
+
+// Delete removes an image and returns it so the caller can remove its blobs.
+func (m MovieImageModel) Delete(movieID, imageID int64) (*MovieImage, error) {
+	query := `
+  DELETE FROM movie_images WHERE movie_id = $1 AND id = $2
+  RETURNING ` + movieImageColumns
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var image MovieImage
+	err := m.DB.QueryRowContext(ctx, query, movieID, imageID).Scan(image.fields()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	return &image, nil
+}
+
+const movieImageColumns = `id,created_at,movie_id,kind,content_type,width,height,size,blob_key,coalesce(thumbnail_key, '')`
+
+func (image *MovieImage) fields() []any {
+	return []any{
+		&image.ID, &image.CreatedAt, &image.MovieID, &image.Kind, &image.ContentType,
+		&image.Width, &image.Height, &image.Size, &image.BlobKey, &image.ThumbnailKey,
+	}
+}
+
+// GetForMovie returns the images of a movie, or none if it is in the trash.
+func (m MovieImageModel) GetForMovie(movieID int64) ([]*MovieImage, error) {
+	query := `
+  SELECT ` + movieImageColumns + `
+  FROM movie_images
+  WHERE movie_id = $1
+  AND EXISTS (SELECT FROM movies WHERE movies.id = movie_id AND movies.deleted_at IS NULL)
+  ORDER BY kind, id
+  `
+	return m.query(query, movieID)
+}
+
+// CheckKey returns ErrNoRecordFound unless key is the blob key or thumbnail
+// key of an image of a movie that is not in the trash.
+func (m MovieImageModel) CheckKey(key string) error {
+	query := `
+  SELECT FROM movie_images
+  INNER JOIN movies ON movies.id = movie_images.movie_id
+  WHERE (movie_images.blob_key = $1 OR movie_images.thumbnail_key = $1)
+  AND movies.deleted_at IS NULL
+  LIMIT 1
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, key).Scan()
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNoRecordFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// GetForMovies returns the images of each of the movies, keyed by movie id,
+// in one query.
+func (m MovieImageModel) GetForMovies(movieIDs []int64) (map[int64][]*MovieImage, error) {
+	query := `
+  SELECT ` + movieImageColumns + `
+  FROM movie_images
+  WHERE movie_id = ANY($1)
+  ORDER BY movie_id, kind, id
+  `
+	images, err := m.query(query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	byMovie := make(map[int64][]*MovieImage, len(movieIDs))
+	for _, image := range images {
+		byMovie[image.MovieID] = append(byMovie[image.MovieID], image)
+	}
+	return byMovie, nil
+}
+
+func (m MovieImageModel) query(query string, args ...any) ([]*MovieImage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	images := []*MovieImage{}
+	for rows.Next() {
+		var image MovieImage
+		err := rows.Scan(image.fields()...)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, &image)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
@@ -12,6 +12,18 @@ var (
 
 type Models struct {
 	Movies     MovieModel
+	Revisions  MovieRevisionModel
+	Reviews    ReviewModel
+	Watchlists WatchlistModel
+	Genres     GenreModel
+	Images     MovieImageModel
+	Titles     MovieTitleModel
+	Similarity SimilarityWeightsModel
+	Relations  MovieRelationModel
+	Collection CollectionModel
+	External   ExternalIDModel
+	People     PersonModel
+	Credits    CreditModel
 	Users      UserModel
 	Tokens     TokenModel
 	Permission PermissionModel
@@ -20,6 +32,18 @@ type Models struct {
 func NewModels(db *sql.DB) Models {
 	return Models{
 		Movies:     MovieModel{DB: db},
+		Revisions:  MovieRevisionModel{DB: db},
+		Reviews:    ReviewModel{DB: db},
+		Watchlists: WatchlistModel{DB: db},
+		Genres:     GenreModel{DB: db},
+		Images:     MovieImageModel{DB: db},
+		Titles:     MovieTitleModel{DB: db},
+		Similarity: SimilarityWeightsModel{DB: db},
+		Relations:  MovieRelationModel{DB: db},
+		Collection: CollectionModel{DB: db},
+		External:   ExternalIDModel{DB: db},
+		People:     PersonModel{DB: db},
+		Credits:    CreditModel{DB: db},
 		Permission: PermissionModel{DB: db},
 		Tokens:     TokenModel{DB: db},
 		Users:      UserModel{DB: db},
@@ -3,8 +3,11 @@ package data
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"cinlim.bikraj.net/internal/validator"
@@ -19,12 +22,53 @@ type Movie struct {
 	Runtime   Runtime   `json:"runtime"`
 	Genres    []string  `json:"genres"`
 	Version   int32     `json:"version"`
+	// Credits and Images are only filled in when asked for with embed.
+	Credits []*Credit     `json:"credits,omitempty"`
+	Images  []*MovieImage `json:"images,omitempty"`
+	// AverageRating and RatingCount summarise the movie's reviews. They are
+	// kept up to date by ReviewModel and are never written through Update.
+	AverageRating float32 `json:"average_rating"`
+	RatingCount   int32   `json:"rating_count"`
+	// DeletedAt is only set on movies read from the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Rank, Headline and Match are only populated by a title search in
+	// GetAll. Match says whether the movie matched strictly or only fuzzily.
+	Rank     float32 `json:"rank,omitempty"`
+	Headline string  `json:"headline,omitempty"`
+	Match    string  `json:"match,omitempty"`
+	// TitleLanguage is set by MovieTitleModel.Localize to the language it
+	// picked for Title. OriginalTitle is only set when that swapped Title for
+	// a localized title.
+	OriginalTitle string `json:"original_title,omitempty"`
+	TitleLanguage string `json:"title_language,omitempty"`
+	// OriginalLanguage is the language Title is written in, if known.
+	OriginalLanguage string `json:"original_language,omitempty"`
+	// ExternalIDs holds the movie's ids in other catalogues, keyed by
+	// namespace. It is only read when asked for with embed; Insert and
+	// Update save any namespaces it holds and leave the rest alone.
+	ExternalIDs map[string]string `json:"external_ids,omitempty"`
+	// RuntimeFormat is the one of RuntimeFormats Runtime is encoded in. It
+	// is set by the API per request; empty means "mins".
+	RuntimeFormat string `json:"-"`
+}
+
+// movieJSON is Movie without its MarshalJSON method.
+type movieJSON Movie
+
+// MarshalJSON encodes the movie with its runtime in RuntimeFormat. The
+// runtime field shadows the one on the embedded movie.
+func (movie Movie) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		*movieJSON
+		Runtime any `json:"runtime"`
+	}{(*movieJSON)(&movie), movie.Runtime.Format(movie.RuntimeFormat)})
 }
 
 func ValidateMovie(v *validator.Validator, input *Movie) {
 
 	v.Check(input.Title != "", "title", "The title cannot be empty")
 	v.Check(len(input.Title) <= 500, "title", "The title cannot be longer than 500 characters.")
+	v.Check(input.OriginalLanguage == "" || validator.Matches(input.OriginalLanguage, LanguageRX), "original_language", "must be a language tag such as fr or pt-br")
 	//Check for Year
 	v.Check(input.Year != 0, "year", "The must be provided")
 	v.Check(input.Year >= 1888, "year", "Must be greater than 1888")
@@ -43,36 +87,148 @@ func ValidateMovie(v *validator.Validator, input *Movie) {
 
 }
 
+// MovieQuery holds the optional filters applied by GetAll. Zero values leave
+// a filter out.
+type MovieQuery struct {
+	Title         string
+	Genres        []string
+	YearMin       int32
+	YearMax       int32
+	RuntimeMin    Runtime
+	RuntimeMax    Runtime
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// PersonID limits the list to movies crediting that person, in Role if
+	// it is set.
+	PersonID int64
+	Role     string
+	// Match is how Title is matched: "strict" full-text search or "fuzzy",
+	// which also forgives accents and typos.
+	Match string
+}
+
+// MatchModes are the ways a title search can be matched.
+var MatchModes = []string{"strict", "fuzzy"}
+
+func ValidateMovieQuery(v *validator.Validator, q MovieQuery) {
+	v.Check(q.YearMin == 0 || q.YearMin >= 1888, "year_min", "Must be greater than 1888")
+	v.Check(q.YearMax == 0 || q.YearMax >= 1888, "year_max", "Must be greater than 1888")
+	v.Check(q.YearMin == 0 || q.YearMax == 0 || q.YearMin <= q.YearMax, "year_min", "must not be greater than year_max")
+	v.Check(q.RuntimeMin >= 0, "runtime_min", "Must be positive")
+	v.Check(q.RuntimeMax >= 0, "runtime_max", "Must be positive")
+	v.Check(q.RuntimeMin == 0 || q.RuntimeMax == 0 || q.RuntimeMin <= q.RuntimeMax, "runtime_min", "must not be greater than runtime_max")
+	v.Check(q.CreatedAfter.IsZero() || q.CreatedBefore.IsZero() || q.CreatedAfter.Before(q.CreatedBefore), "created_after", "must be before created_before")
+	v.Check(q.PersonID >= 0, "person_id", "must be a positive integer")
+	v.Check(q.Role == "" || validator.In(q.Role, CreditRoles...), "role", "must be one of director, writer or actor")
+	v.Check(q.Role == "" || q.PersonID > 0, "role", "can only be used together with person_id")
+	v.Check(q.Match == "" || validator.In(q.Match, MatchModes...), "match", "must be strict or fuzzy")
+}
+
+// nullTime maps the zero time to NULL so optional bounds can be skipped in SQL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
 type MovieModel struct {
 	DB *sql.DB
 }
 
-func (m MovieModel) Insert(movie *Movie) error {
+// Insert adds a movie and records its first revision against userID.
+func (m MovieModel) Insert(movie *Movie, userID int64) error {
 	query := `
-  INSERT INTO movies(title,year,runtime,genres)
-  VALUES($1,$2,$3,$4)
+  INSERT INTO movies(title,year,runtime,genres,original_language)
+  VALUES($1,$2,$3,$4,$5)
   RETURNING id, created_at,version
   `
-	args := []interface{}{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)}
+	args := []interface{}{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), movie.OriginalLanguage}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	// Using queryRow() as we need to execute the row
-	return m.DB.QueryRow(query, args...).Scan(&movie.Id, &movie.CreatedAt, &movie.Version)
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.Id, &movie.CreatedAt, &movie.Version)
+	if err != nil {
+		return err
+	}
+	err = saveExternalIDs(ctx, tx, movie.Id, movie.ExternalIDs)
+	if err != nil {
+		return err
+	}
+	err = recordRevision(ctx, tx, movie.Id, userID, RevisionInsert)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// InsertBatch inserts all movies in a single transaction, filling in their
+// ids, created_at and version. Either every movie is written or none are.
+func (m MovieModel) InsertBatch(movies []*Movie, userID int64) error {
+	query := `
+  INSERT INTO movies(title,year,runtime,genres,original_language)
+  VALUES($1,$2,$3,$4,$5)
+  RETURNING id, created_at,version
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, movie := range movies {
+		args := []interface{}{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), movie.OriginalLanguage}
+		err = stmt.QueryRowContext(ctx, args...).Scan(&movie.Id, &movie.CreatedAt, &movie.Version)
+		if err != nil {
+			return err
+		}
+		err = recordRevision(ctx, tx, movie.Id, userID, RevisionInsert)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
 func (m MovieModel) Get(id int64) (*Movie, error) {
+	return m.GetFields(id, nil)
+}
+
+// GetFields reads only the columns behind fields, which must come from
+// MovieFields. Every field is read when fields is empty.
+func (m MovieModel) GetFields(id int64, fields []string) (*Movie, error) {
 
 	if id < 1 {
 		return nil, ErrNoRecordFound
 	}
 
-	query := `
-  SELECT id,created_at,title,year,runtime,genres, version
-  FROM movies
-  Where id = $1 
-  `
 	var movie Movie
+	columns := movieColumns(fields, MovieFields)
+	query := fmt.Sprintf(`
+  SELECT %s
+  FROM (
+    SELECT id,created_at,title,year,runtime,genres,version,average_rating AS rating,rating_count,original_language
+    FROM movies
+    Where id = $1 AND deleted_at IS NULL
+  ) AS movies
+  `, strings.Join(columns, ","))
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(&movie.Id, &movie.CreatedAt, &movie.Title, &movie.Year, &movie.Runtime, pq.Array(&movie.Genres), &movie.Version)
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(movie.columnDests(columns)...)
 
 	if err != nil {
 		switch {
@@ -86,12 +242,50 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 	return &movie, nil
 }
 
-func (m MovieModel) Update(movie *Movie) error {
+// GetMany reads the live movies among ids in a single query. Movies come
+// back in no particular order and missing ids are simply absent.
+func (m MovieModel) GetMany(ids []int64) ([]*Movie, error) {
+	columns := movieColumns(nil, MovieFields)
+	query := fmt.Sprintf(`
+  SELECT %s
+  FROM (
+    SELECT id,created_at,title,year,runtime,genres,version,average_rating AS rating,rating_count,original_language
+    FROM movies
+    WHERE id = ANY($1) AND deleted_at IS NULL
+  ) AS movies
+  `, strings.Join(columns, ","))
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movies := []*Movie{}
+	for rows.Next() {
+		var movie Movie
+		err := rows.Scan(movie.columnDests(columns)...)
+		if err != nil {
+			return nil, err
+		}
+		movies = append(movies, &movie)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return movies, nil
+}
+
+// Update writes movie back if its version still matches, recording the new
+// revision against userID.
+func (m MovieModel) Update(movie *Movie, userID int64) error {
 	query :=
 		`
   UPDATE movies 
-  SET title = $1,year=$2,runtime=$3,genres=$4,version=version+1
-  WHERE id = $5  AND version  = $6
+  SET title = $1,year=$2,runtime=$3,genres=$4,original_language=$7,version=version+1
+  WHERE id = $5  AND version  = $6 AND deleted_at IS NULL
   RETURNING version
   `
 	args := []any{
@@ -101,11 +295,19 @@ func (m MovieModel) Update(movie *Movie) error {
 		pq.Array(movie.Genres),
 		movie.Id,
 		movie.Version,
+		movie.OriginalLanguage,
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
 	if err != nil {
 		switch {
 
@@ -115,16 +317,40 @@ func (m MovieModel) Update(movie *Movie) error {
 			return err
 		}
 	}
-	return nil
+	err = saveExternalIDs(ctx, tx, movie.Id, movie.ExternalIDs)
+	if err != nil {
+		return err
+	}
+	err = recordRevision(ctx, tx, movie.Id, userID, RevisionUpdate)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
-func (m MovieModel) Delete(id int64) error {
+
+// Delete moves a movie to the trash. It stays there until Restore brings it
+// back or PurgeDeleted removes it for good. A non-zero version makes the
+// delete conditional on the movie not having changed since it was read.
+func (m MovieModel) Delete(id int64, version int32, userID int64) error {
 	if id < 1 {
 		return ErrNoRecordFound
 	}
 	query :=
 		`
-DELETE FROM movies WHERE id = $1`
-	result, err := m.DB.Exec(query, id)
+  UPDATE movies
+  SET deleted_at = NOW(), version = version + 1
+  WHERE id = $1 AND deleted_at IS NULL AND (version = $2 OR $2 = 0)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, id, version)
 	if err != nil {
 		return err
 	}
@@ -133,28 +359,113 @@ DELETE FROM movies WHERE id = $1`
 		return err
 	}
 	if rowsAffected == 0 {
+		if version != 0 {
+			return ErrEditConflict
+		}
 		return ErrNoRecordFound
 	}
-	return nil
+	err = recordRevision(ctx, tx, id, userID, RevisionDelete)
+	if err != nil {
+		return err
+	}
+	// A deleted movie drops off every watchlist, even if it is restored later.
+	_, err = tx.ExecContext(ctx, `DELETE FROM watchlist_entries WHERE movie_id = $1`, id)
+	if err != nil {
+		return err
+	}
+	// Relations, collection memberships and external ids are kept rather
+	// than removed, so Restore brings them back as they were. While the
+	// movie is in the trash they count as dangling: GetGraph, collection
+	// reads and every relation and membership write skip them, and purging
+	// cascades to them. An external id stays taken while its movie is in
+	// the trash.
+	return tx.Commit()
 }
 
-func (m MovieModel) GetAll(title string, genres []string, filters Filter) ([]*Movie, PageMetaData, error) {
-	query := fmt.Sprintf(`   
-  SELECT count(*) OVER(),id,created_at,title,year,runtime,genres,version
+// GetDeleted reads a movie that is in the trash.
+func (m MovieModel) GetDeleted(id int64) (*Movie, error) {
+	if id < 1 {
+		return nil, ErrNoRecordFound
+	}
+	query := `
+  SELECT id,created_at,title,year,runtime,genres,version,average_rating,rating_count,original_language
   FROM movies
-  WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
-  AND (genres @> $2 OR $2 ='{}')
-  ORDER BY  %s %s ,id ASC
-  LIMIT $3 OFFSET $4
-  `, filters.sortColumn(), filters.sortDirection())
-
+  WHERE id = $1 AND deleted_at IS NOT NULL
+  `
+	var movie Movie
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
 
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&movie.Id, &movie.CreatedAt, &movie.Title, &movie.Year, &movie.Runtime, pq.Array(&movie.Genres), &movie.Version, &movie.AverageRating, &movie.RatingCount, &movie.OriginalLanguage)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	return &movie, nil
+}
+
+// Restore takes a movie back out of the trash. A non-zero version makes the
+// restore conditional on the movie not having changed since it was read.
+func (m MovieModel) Restore(id int64, version int32, userID int64) (*Movie, error) {
+	if id < 1 {
+		return nil, ErrNoRecordFound
+	}
+	query := `
+  UPDATE movies
+  SET deleted_at = NULL, version = version + 1
+  WHERE id = $1 AND deleted_at IS NOT NULL AND (version = $2 OR $2 = 0)
+  RETURNING id,created_at,title,year,runtime,genres,version,average_rating,rating_count,original_language
+  `
+	var movie Movie
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	fmt.Println(title)
-	rows, err := m.DB.QueryContext(ctx, query, title, pq.Array(genres), filters.PageSize, filters.Page)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, id, version).Scan(&movie.Id, &movie.CreatedAt, &movie.Title, &movie.Year, &movie.Runtime, pq.Array(&movie.Genres), &movie.Version, &movie.AverageRating, &movie.RatingCount, &movie.OriginalLanguage)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows) && version != 0:
+			return nil, ErrEditConflict
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	err = recordRevision(ctx, tx, id, userID, RevisionRestore)
 	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return &movie, nil
+}
+
+// GetTrash lists the movies that have been deleted but not yet purged.
+func (m MovieModel) GetTrash(filters Filter) ([]*Movie, PageMetaData, error) {
+	query := fmt.Sprintf(`
+  SELECT count(*) OVER(),id,created_at,title,year,runtime,genres,version,average_rating,rating_count,original_language,deleted_at
+  FROM movies
+  WHERE deleted_at IS NOT NULL
+  ORDER BY %s %s, id ASC
+  LIMIT $1 OFFSET $2
+  `, filters.sortColumn(), filters.sortDirection())
 
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
 		return nil, PageMetaData{}, err
 	}
 	defer rows.Close()
@@ -172,6 +483,10 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filter) ([]*Mo
 			&movie.Runtime,
 			pq.Array(&movie.Genres),
 			&movie.Version,
+			&movie.AverageRating,
+			&movie.RatingCount,
+			&movie.OriginalLanguage,
+			&movie.DeletedAt,
 		)
 		if err != nil {
 			return nil, PageMetaData{}, err
@@ -181,6 +496,426 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filter) ([]*Mo
 	if err := rows.Err(); err != nil {
 		return nil, PageMetaData{}, err
 	}
-
 	return movies, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
 }
+
+// PurgeDeleted permanently removes movies that went into the trash before
+// the cutoff. It returns how many were removed and the images that went with
+// them, so their blobs can be deleted too. The movies are locked first, which
+// makes an image upload racing the purge either finish before it, and be
+// returned, or fail because the movie is gone.
+func (m MovieModel) PurgeDeleted(before time.Time) (int64, []*MovieImage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	var ids []int64
+	err = tx.QueryRowContext(ctx, `
+  SELECT coalesce(array_agg(id), '{}') FROM (
+    SELECT id FROM movies
+    WHERE deleted_at IS NOT NULL AND deleted_at < $1
+    FOR UPDATE
+  ) AS purgeable
+  `, before).Scan(pq.Array(&ids))
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(ids) == 0 {
+		return 0, nil, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+  DELETE FROM movie_images
+  WHERE movie_id = ANY($1)
+  RETURNING `+movieImageColumns, pq.Array(ids))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+	images := []*MovieImage{}
+	for rows.Next() {
+		var image MovieImage
+		err := rows.Scan(image.fields()...)
+		if err != nil {
+			return 0, nil, err
+		}
+		images = append(images, &image)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM movies WHERE id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return 0, nil, err
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, nil, err
+	}
+	return purged, images, nil
+}
+
+// movieSortKeys maps each sortable column to the SQL type its cursor value is
+// cast to in the keyset condition.
+var movieSortKeys = map[string]string{
+	"id":        "bigint",
+	"title":     "text",
+	"year":      "integer",
+	"runtime":   "integer",
+	"relevance": "real",
+	"rating":    "real",
+}
+
+// MovieFields are the fields a client can pick with fields= on a single
+// movie. MovieListFields adds the title search extras a listing can return.
+var (
+	MovieFields     = []string{"id", "created_at", "title", "original_language", "year", "runtime", "genres", "version", "average_rating", "rating_count"}
+	MovieListFields = []string{"id", "created_at", "title", "original_language", "year", "runtime", "genres", "version", "average_rating", "rating_count", "rank", "headline", "match"}
+)
+
+// movieFieldColumns maps each JSON field to the column Get and GetAll select
+// it as.
+var movieFieldColumns = map[string]string{
+	"average_rating": "rating",
+	"rank":           "relevance",
+}
+
+// movieColumns lists the columns to select for fields, always including id.
+// Every field in all is selected when fields is empty.
+func movieColumns(fields, all []string) []string {
+	if len(fields) == 0 {
+		fields = all
+	}
+	columns := []string{"id"}
+	for _, field := range fields {
+		column, ok := movieFieldColumns[field]
+		if !ok {
+			column = field
+		}
+		if !validator.In(column, columns...) {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// columnDests returns the scan destinations for columns.
+func (movie *Movie) columnDests(columns []string) []any {
+	dests := make([]any, len(columns))
+	for i, column := range columns {
+		switch column {
+		case "id":
+			dests[i] = &movie.Id
+		case "created_at":
+			dests[i] = &movie.CreatedAt
+		case "title":
+			dests[i] = &movie.Title
+		case "original_language":
+			dests[i] = &movie.OriginalLanguage
+		case "year":
+			dests[i] = &movie.Year
+		case "runtime":
+			dests[i] = &movie.Runtime
+		case "genres":
+			dests[i] = pq.Array(&movie.Genres)
+		case "version":
+			dests[i] = &movie.Version
+		case "rating":
+			dests[i] = &movie.AverageRating
+		case "rating_count":
+			dests[i] = &movie.RatingCount
+		case "relevance":
+			dests[i] = &movie.Rank
+		case "headline":
+			dests[i] = &movie.Headline
+		case "match":
+			dests[i] = &movie.Match
+		default:
+			panic("unknown movie column: " + column)
+		}
+	}
+	return dests
+}
+
+// sortKey returns the value of column for this movie as stored in a cursor.
+func (movie *Movie) sortKey(column string) string {
+	switch column {
+	case "title":
+		return movie.Title
+	case "year":
+		return strconv.FormatInt(int64(movie.Year), 10)
+	case "runtime":
+		return strconv.FormatInt(int64(movie.Runtime), 10)
+	case "relevance":
+		return strconv.FormatFloat(float64(movie.Rank), 'g', -1, 32)
+	case "rating":
+		return strconv.FormatFloat(float64(movie.AverageRating), 'g', -1, 32)
+	default:
+		return strconv.FormatInt(movie.Id, 10)
+	}
+}
+
+// strictTitleMatch matches $1 as a full-text query against the title or any
+// localized title.
+const strictTitleMatch = `(to_tsvector('simple', title) @@ websearch_to_tsquery('simple', $1) OR EXISTS (
+      SELECT 1 FROM movie_titles
+      WHERE movie_titles.movie_id = movies.id
+      AND to_tsvector('simple', movie_titles.title) @@ websearch_to_tsquery('simple', $1)
+    ))`
+
+// fuzzyTitleMatch compares $1 and the title, or any localized title, with
+// accents stripped, and by trigram word similarity so a typo still matches.
+// Both sides use the expressions movies_title_fuzzy_idx and
+// movie_titles_title_fuzzy_idx are built on.
+const fuzzyTitleMatch = `(immutable_unaccent(lower($1)) <% immutable_unaccent(lower(title)) OR EXISTS (
+      SELECT 1 FROM movie_titles
+      WHERE movie_titles.movie_id = movies.id
+      AND immutable_unaccent(lower($1)) <% immutable_unaccent(lower(movie_titles.title))
+    ))`
+
+// titleMatch returns the SQL condition matching $1 against titles in q's mode.
+func (q MovieQuery) titleMatch() string {
+	if q.Match == "fuzzy" {
+		return "(" + strictTitleMatch + " OR " + fuzzyTitleMatch + ")"
+	}
+	return strictTitleMatch
+}
+
+// relevance returns the SQL expression a title search ranks column by.
+// Fuzzy matches have no full-text rank, so their word similarity stands in.
+func (q MovieQuery) relevance(column string) string {
+	rank := `ts_rank(to_tsvector('simple', ` + column + `), websearch_to_tsquery('simple', $1))`
+	if q.Match == "fuzzy" {
+		return "greatest(" + rank + ", word_similarity(immutable_unaccent(lower($1)), immutable_unaccent(lower(" + column + "))))"
+	}
+	return rank
+}
+
+// bestTitle returns a lateral join picking, for a title search, whichever of
+// the movie's title and its localized titles ranks highest, as
+// best.matched_title and best.rank. Both are NULL when there is no search, so
+// the titles are only ranked when they need to be.
+func (q MovieQuery) bestTitle() string {
+	return `LEFT JOIN LATERAL (
+      SELECT titles.title AS matched_title, ` + q.relevance("titles.title") + ` AS rank
+      FROM (
+        SELECT movies.title
+        UNION ALL
+        SELECT movie_titles.title FROM movie_titles WHERE movie_titles.movie_id = movies.id
+      ) AS titles
+      WHERE $1 <> ''
+      ORDER BY rank DESC
+      LIMIT 1
+    ) AS best ON true`
+}
+
+// where returns the WHERE conditions for q with their arguments. The title is
+// always $1 so callers can reuse it in the select list; callers number their
+// own placeholders from len(args)+1.
+func (q MovieQuery) where() (string, []any) {
+	conditions := `
+    deleted_at IS NULL
+    AND ($1 = '' OR ` + q.titleMatch() + `)
+    AND (genres @> $2 OR $2 ='{}')
+    AND (year >= $3 OR $3 = 0)
+    AND (year <= $4 OR $4 = 0)
+    AND (runtime >= $5 OR $5 = 0)
+    AND (runtime <= $6 OR $6 = 0)
+    AND (created_at >= $7 OR $7 IS NULL)
+    AND (created_at < $8 OR $8 IS NULL)
+    AND ($9 = 0 OR EXISTS (
+      SELECT 1 FROM credits
+      WHERE credits.movie_id = movies.id AND credits.person_id = $9 AND ($10 = '' OR credits.role = $10)
+    ))
+  `
+	args := []any{
+		q.Title,
+		pq.Array(q.Genres),
+		q.YearMin,
+		q.YearMax,
+		q.RuntimeMin,
+		q.RuntimeMax,
+		nullTime(q.CreatedAfter),
+		nullTime(q.CreatedBefore),
+		q.PersonID,
+		q.Role,
+	}
+	return conditions, args
+}
+
+// GetAll lists the movies matching q. Only the columns behind fields, plus
+// the id and sort column the page cursors need, are selected; every field is
+// selected when fields is empty.
+func (m MovieModel) GetAll(q MovieQuery, filters Filter, fields []string) ([]*Movie, PageMetaData, error) {
+	total := "0"
+	if filters.IncludeTotal {
+		total = "count(*) OVER()"
+	}
+	where, args := q.where()
+	n := len(args)
+	column := filters.sortColumn()
+	columns := movieColumns(fields, MovieListFields)
+	if !validator.In(column, columns...) {
+		columns = append(columns, column)
+	}
+	keyset, orderBy := filters.keyset(fmt.Sprintf("$%d::%s", n+3, movieSortKeys[column]), fmt.Sprintf("$%d", n+4))
+	args = append(args, filters.limit()+1, filters.offset())
+	if c := filters.cursor(); c != nil {
+		args = append(args, c.Key, c.ID)
+	}
+
+	// The title is parsed with websearch_to_tsquery so quoted phrases, OR and
+	// -exclude work. The tsvector expression matches movies_title_idx so the
+	// GIN index is still used for the match itself. Rank and headline come
+	// from the best matching of the movie's titles, so a movie found by a
+	// localized title is ranked and highlighted by that title. The keyset
+	// condition sits outside the subquery so it can refer to the relevance
+	// alias.
+	query := fmt.Sprintf(`
+  SELECT total,%s
+  FROM (
+    SELECT %s AS total,id,created_at,title,original_language,year,runtime,genres,version,
+      average_rating AS rating,rating_count,
+      coalesce(best.rank, 0) AS relevance,
+      CASE WHEN $1 = '' THEN ''
+        ELSE ts_headline('simple', best.matched_title, websearch_to_tsquery('simple', $1), 'StartSel=<b>, StopSel=</b>, HighlightAll=true')
+      END AS headline,
+      CASE WHEN $1 = '' THEN ''
+        WHEN %s THEN 'strict'
+        ELSE 'fuzzy'
+      END AS match
+    FROM movies
+    %s
+    WHERE %s
+  ) AS movies
+  WHERE %s
+  ORDER BY %s
+  LIMIT $%d OFFSET $%d
+  `, strings.Join(columns, ","), total, strictTitleMatch, q.bestTitle(), where, keyset, orderBy, n+1, n+2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+
+		return nil, PageMetaData{}, err
+	}
+	defer rows.Close()
+	movies := []*Movie{}
+	var totalRecords int
+
+	for rows.Next() {
+		var movie Movie
+		err := rows.Scan(append([]any{&totalRecords}, movie.columnDests(columns)...)...)
+		if err != nil {
+			return nil, PageMetaData{}, err
+		}
+		movies = append(movies, &movie)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, PageMetaData{}, err
+	}
+
+	// One extra row is fetched to find out whether another page follows.
+	hasMore := len(movies) > filters.limit()
+	if hasMore {
+		movies = movies[:filters.limit()]
+	}
+	if c := filters.cursor(); c != nil && c.Prev {
+		for i, j := 0, len(movies)-1; i < j; i, j = i+1, j-1 {
+			movies[i], movies[j] = movies[j], movies[i]
+		}
+	}
+
+	metadata := PageMetaData{PageSize: filters.PageSize}
+	if filters.IncludeTotal {
+		metadata = calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	}
+	if len(movies) > 0 {
+		first, last := movies[0], movies[len(movies)-1]
+		filters.pageCursors(&metadata, first.sortKey(column), first.Id, last.sortKey(column), last.Id, hasMore)
+	}
+	return movies, metadata, nil
+}
+
+// Export walks every movie matching q in the order given by filters, calling
+// fn once per movie. Rows are read through a server-side cursor a batch at a
+// time so memory use does not grow with the size of the catalogue. Paging
+// fields on filters are ignored.
+func (m MovieModel) Export(q MovieQuery, filters Filter, fn func(*Movie) error) error {
+	where, args := q.where()
+	query := fmt.Sprintf(`
+  DECLARE movie_export NO SCROLL CURSOR FOR
+  SELECT id,created_at,title,original_language,year,runtime,genres,version,
+    average_rating AS rating,rating_count,
+    coalesce(best.rank, 0) AS relevance
+  FROM movies
+  %s
+  WHERE %s
+  ORDER BY %s %s, id ASC
+  `, q.bestTitle(), where, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	// A cursor only lives as long as its transaction.
+	tx, err := m.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	for {
+		rows, err := tx.QueryContext(ctx, `FETCH 500 FROM movie_export`)
+		if err != nil {
+			return err
+		}
+		fetched := 0
+		for rows.Next() {
+			var movie Movie
+			err := rows.Scan(
+				&movie.Id,
+				&movie.CreatedAt,
+				&movie.Title,
+				&movie.OriginalLanguage,
+				&movie.Year,
+				&movie.Runtime,
+				pq.Array(&movie.Genres),
+				&movie.Version,
+				&movie.AverageRating,
+				&movie.RatingCount,
+				&movie.Rank,
+			)
+			if err == nil {
+				err = fn(&movie)
+			}
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			fetched++
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+		if fetched == 0 {
+			return tx.Commit()
+		}
+	}
+}
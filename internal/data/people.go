@@ -0,0 +1,152 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"cinlim.bikraj.net/internal/validator"
+)
+
+type Person struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name"`
+	BirthYear int32     `json:"birth_year,omitempty"`
+	Biography string    `json:"biography,omitempty"`
+	Version   int32     `json:"version"`
+}
+
+func ValidatePerson(v *validator.Validator, person *Person) {
+	v.Check(person.Name != "", "name", "must be provided")
+	v.Check(len(person.Name) <= 500, "name", "must not be more than 500 bytes long")
+	v.Check(person.BirthYear == 0 || person.BirthYear >= 1800, "birth_year", "Must be greater than 1800")
+	v.Check(person.BirthYear <= int32(time.Now().Year()), "birth_year", "cannot be in the future")
+	v.Check(len(person.Biography) <= 10_000, "biography", "must not be more than 10000 bytes long")
+}
+
+type PersonModel struct {
+	DB *sql.DB
+}
+
+func (m PersonModel) Insert(person *Person) error {
+	query := `
+  INSERT INTO people (name,birth_year,biography)
+  VALUES ($1,$2,$3)
+  RETURNING id,created_at,version
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, person.Name, person.BirthYear, person.Biography).Scan(&person.ID, &person.CreatedAt, &person.Version)
+}
+
+func (m PersonModel) Get(id int64) (*Person, error) {
+	if id < 1 {
+		return nil, ErrNoRecordFound
+	}
+	query := `
+  SELECT id,created_at,name,birth_year,biography,version
+  FROM people
+  WHERE id = $1
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var person Person
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&person.ID, &person.CreatedAt, &person.Name, &person.BirthYear, &person.Biography, &person.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	return &person, nil
+}
+
+func (m PersonModel) Update(person *Person) error {
+	query := `
+  UPDATE people
+  SET name = $1, birth_year = $2, biography = $3, version = version + 1
+  WHERE id = $4 AND version = $5
+  RETURNING version
+  `
+	args := []any{person.Name, person.BirthYear, person.Biography, person.ID, person.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&person.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes a person along with all of their credits.
+func (m PersonModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrNoRecordFound
+	}
+	query := `
+  DELETE FROM people WHERE id = $1
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+	return nil
+}
+
+func (m PersonModel) GetAll(name string, filters Filter) ([]*Person, PageMetaData, error) {
+	query := fmt.Sprintf(`
+  SELECT count(*) OVER(),id,created_at,name,birth_year,biography,version
+  FROM people
+  WHERE (name ILIKE '%%' || $1 || '%%' OR $1 = '')
+  ORDER BY %s %s, id ASC
+  LIMIT $2 OFFSET $3
+  `, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, PageMetaData{}, err
+	}
+	defer rows.Close()
+
+	people := []*Person{}
+	var totalRecords int
+	for rows.Next() {
+		var person Person
+		err := rows.Scan(&totalRecords, &person.ID, &person.CreatedAt, &person.Name, &person.BirthYear, &person.Biography, &person.Version)
+		if err != nil {
+			return nil, PageMetaData{}, err
+		}
+		people = append(people, &person)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, PageMetaData{}, err
+	}
+	return people, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
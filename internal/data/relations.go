@@ -0,0 +1,182 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"cinlim.bikraj.net/internal/validator"
+	"github.com/lib/pq"
+)
+
+var RelationKinds = []string{"sequel", "prequel", "remake", "spin-off"}
+
+// MaxRelationDepth bounds how far GetGraph will walk from a movie.
+const MaxRelationDepth = 5
+
+var ErrDuplicateRelation = errors.New("duplicate relation")
+
+// Relation is a directed link between two movies, read as "MovieID is a
+// Kind of RelatedMovieID".
+type Relation struct {
+	ID             int64     `json:"id"`
+	MovieID        int64     `json:"movie_id"`
+	RelatedMovieID int64     `json:"related_movie_id"`
+	Kind           string    `json:"kind"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func ValidateRelation(v *validator.Validator, relation *Relation) {
+	v.Check(relation.RelatedMovieID > 0, "related_movie_id", "must be provided")
+	v.Check(relation.RelatedMovieID != relation.MovieID, "related_movie_id", "must not be the movie itself")
+	v.Check(validator.In(relation.Kind, RelationKinds...), "kind", "must be one of sequel, prequel, remake or spin-off")
+}
+
+// RelatedMovie is a movie reached while walking the relation graph. Depth is
+// the number of links between it and the movie the walk started from.
+type RelatedMovie struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+	Year  int32  `json:"year,omitempty"`
+	Depth int    `json:"depth"`
+}
+
+// RelationGraph is the part of the relation graph around one movie.
+type RelationGraph struct {
+	Movies    []*RelatedMovie `json:"movies"`
+	Relations []*Relation     `json:"relations"`
+}
+
+type MovieRelationModel struct {
+	DB *sql.DB
+}
+
+func (m MovieRelationModel) Insert(relation *Relation) error {
+	query := `
+  INSERT INTO movie_relations (movie_id,related_movie_id,kind)
+  SELECT $1,$2,$3
+  WHERE EXISTS (SELECT 1 FROM movies WHERE id = $2 AND deleted_at IS NULL)
+  RETURNING id,created_at
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, relation.MovieID, relation.RelatedMovieID, relation.Kind).Scan(&relation.ID, &relation.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNoRecordFound
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateRelation
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrNoRecordFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes a relation starting or ending at movieID. A relation with
+// either end in the trash is not found, as GetGraph does not show it.
+func (m MovieRelationModel) Delete(movieID, relationID int64) error {
+	query := `
+  DELETE FROM movie_relations r
+  WHERE r.id = $2 AND (r.movie_id = $1 OR r.related_movie_id = $1)
+  AND NOT EXISTS (
+    SELECT 1 FROM movies
+    WHERE id IN (r.movie_id, r.related_movie_id) AND deleted_at IS NOT NULL
+  )
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, relationID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+	return nil
+}
+
+// GetGraph walks relations in both directions from movieID, up to depth
+// links away, and returns every movie reached along with the relations
+// between them. The starting movie is included at depth 0.
+func (m MovieRelationModel) GetGraph(movieID int64, depth int) (*RelationGraph, error) {
+	query := `
+  WITH RECURSIVE walk(id, depth) AS (
+    SELECT $1::bigint, 0
+    UNION
+    SELECT n.id, w.depth + 1
+    FROM walk w
+    INNER JOIN movie_relations r ON w.id IN (r.movie_id, r.related_movie_id)
+    INNER JOIN movies n ON n.id = CASE WHEN r.movie_id = w.id THEN r.related_movie_id ELSE r.movie_id END
+    WHERE w.depth < $2 AND n.deleted_at IS NULL
+  )
+  SELECT m.id,m.title,m.year,min(w.depth) AS depth
+  FROM walk w
+  INNER JOIN movies m ON m.id = w.id
+  WHERE m.deleted_at IS NULL
+  GROUP BY m.id
+  ORDER BY depth, m.year, m.id
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID, depth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	graph := &RelationGraph{Movies: []*RelatedMovie{}, Relations: []*Relation{}}
+	ids := []int64{}
+	for rows.Next() {
+		var movie RelatedMovie
+		err := rows.Scan(&movie.ID, &movie.Title, &movie.Year, &movie.Depth)
+		if err != nil {
+			return nil, err
+		}
+		graph.Movies = append(graph.Movies, &movie)
+		ids = append(ids, movie.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(graph.Movies) == 0 {
+		return nil, ErrNoRecordFound
+	}
+
+	query = `
+  SELECT id,movie_id,related_movie_id,kind,created_at
+  FROM movie_relations
+  WHERE movie_id = ANY($1) AND related_movie_id = ANY($1)
+  ORDER BY id
+  `
+	rows, err = m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var relation Relation
+		err := rows.Scan(&relation.ID, &relation.MovieID, &relation.RelatedMovieID, &relation.Kind, &relation.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		graph.Relations = append(graph.Relations, &relation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return graph, nil
+}
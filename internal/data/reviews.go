@@ -0,0 +1,203 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"cinlim.bikraj.net/internal/validator"
+)
+
+type Review struct {
+	ID        int64     `json:"id"`
+	MovieID   int64     `json:"movie_id"`
+	UserID    int64     `json:"user_id"`
+	Rating    int32     `json:"rating"`
+	Body      string    `json:"review,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   int32     `json:"version"`
+}
+
+func ValidateReview(v *validator.Validator, review *Review) {
+	v.Check(review.Rating >= 1, "rating", "must be at least 1")
+	v.Check(review.Rating <= 10, "rating", "must not be more than 10")
+	v.Check(len(review.Body) <= 10_000, "review", "must not be more than 10000 bytes long")
+}
+
+type ReviewModel struct {
+	DB *sql.DB
+}
+
+// lockRatings takes a row lock on the movie for the rest of the transaction.
+// Review writes take it before touching reviews, so concurrent writes to
+// the same movie's reviews run one after another and each refreshRatings
+// sees the rows committed before it.
+func lockRatings(ctx context.Context, tx *sql.Tx, movieID int64) error {
+	_, err := tx.ExecContext(ctx, `SELECT id FROM movies WHERE id = $1 FOR UPDATE`, movieID)
+	return err
+}
+
+// refreshRatings recomputes a movie's average_rating and rating_count from its
+// reviews. It runs inside the review write's transaction, after lockRatings,
+// so the aggregate cannot drift from the rows it summarises.
+func refreshRatings(ctx context.Context, tx *sql.Tx, movieID int64) error {
+	query := `
+  UPDATE movies
+  SET average_rating = COALESCE(r.average, 0), rating_count = r.count
+  FROM (SELECT avg(rating)::real AS average, count(*) AS count FROM reviews WHERE movie_id = $1) AS r
+  WHERE movies.id = $1
+  `
+	_, err := tx.ExecContext(ctx, query, movieID)
+	return err
+}
+
+// Upsert creates the user's review of a movie, or replaces it if they have
+// already reviewed it.
+func (m ReviewModel) Upsert(review *Review) error {
+	query := `
+  INSERT INTO reviews (movie_id,user_id,rating,body)
+  VALUES ($1,$2,$3,$4)
+  ON CONFLICT (movie_id,user_id) DO UPDATE
+  SET rating = EXCLUDED.rating, body = EXCLUDED.body, updated_at = NOW(), version = reviews.version + 1
+  RETURNING id,created_at,updated_at,version
+  `
+	args := []any{review.MovieID, review.UserID, review.Rating, review.Body}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = lockRatings(ctx, tx, review.MovieID)
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&review.ID, &review.CreatedAt, &review.UpdatedAt, &review.Version)
+	if err != nil {
+		return err
+	}
+	err = refreshRatings(ctx, tx, review.MovieID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m ReviewModel) Delete(movieID, userID int64) error {
+	query := `
+  DELETE FROM reviews
+  WHERE movie_id = $1 AND user_id = $2
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = lockRatings(ctx, tx, movieID)
+	if err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, query, movieID, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+	err = refreshRatings(ctx, tx, movieID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m ReviewModel) GetAllForMovie(movieID int64, filters Filter) ([]*Review, PageMetaData, error) {
+	query := fmt.Sprintf(`
+  SELECT count(*) OVER(),id,movie_id,user_id,rating,body,created_at,updated_at,version
+  FROM reviews
+  WHERE movie_id = $1
+  ORDER BY %s %s, id ASC
+  LIMIT $2 OFFSET $3
+  `, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, PageMetaData{}, err
+	}
+	defer rows.Close()
+
+	reviews := []*Review{}
+	var totalRecords int
+	for rows.Next() {
+		var review Review
+		err := rows.Scan(
+			&totalRecords,
+			&review.ID,
+			&review.MovieID,
+			&review.UserID,
+			&review.Rating,
+			&review.Body,
+			&review.CreatedAt,
+			&review.UpdatedAt,
+			&review.Version,
+		)
+		if err != nil {
+			return nil, PageMetaData{}, err
+		}
+		reviews = append(reviews, &review)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, PageMetaData{}, err
+	}
+	return reviews, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// GetForUser returns the review a user has left on a movie.
+func (m ReviewModel) GetForUser(movieID, userID int64) (*Review, error) {
+	query := `
+  SELECT id,movie_id,user_id,rating,body,created_at,updated_at,version
+  FROM reviews
+  WHERE movie_id = $1 AND user_id = $2
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var review Review
+	err := m.DB.QueryRowContext(ctx, query, movieID, userID).Scan(
+		&review.ID,
+		&review.MovieID,
+		&review.UserID,
+		&review.Rating,
+		&review.Body,
+		&review.CreatedAt,
+		&review.UpdatedAt,
+		&review.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	return &review, nil
+}
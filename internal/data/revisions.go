@@ -0,0 +1,207 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	RevisionInsert  = "insert"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+)
+
+// MovieRevision is a snapshot of a movie as it stood after one write.
+type MovieRevision struct {
+	ID        int64     `json:"id"`
+	MovieID   int64     `json:"movie_id"`
+	Version   int32     `json:"version"`
+	UserID    *int64    `json:"user_id"`
+	Operation string    `json:"operation"`
+	CreatedAt time.Time `json:"created_at"`
+	Title     string    `json:"title"`
+	Year      int32     `json:"year"`
+	Runtime   Runtime   `json:"runtime"`
+	Genres    []string  `json:"genres"`
+	// OriginalLanguage works as it does on Movie.
+	OriginalLanguage string `json:"original_language,omitempty"`
+	// RuntimeFormat works as it does on Movie.
+	RuntimeFormat string `json:"-"`
+	// movieCreatedAt is when the movie itself was first inserted.
+	movieCreatedAt time.Time
+}
+
+// revisionJSON is MovieRevision without its MarshalJSON method.
+type revisionJSON MovieRevision
+
+// MarshalJSON encodes the revision with its runtime in RuntimeFormat, as
+// Movie.MarshalJSON does.
+func (r MovieRevision) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		*revisionJSON
+		Runtime any `json:"runtime"`
+	}{(*revisionJSON)(&r), r.Runtime.Format(r.RuntimeFormat)})
+}
+
+// Movie returns the revision's snapshot in the shape of a live movie.
+func (r *MovieRevision) Movie() *Movie {
+	return &Movie{
+		Id:               r.MovieID,
+		CreatedAt:        r.movieCreatedAt,
+		Title:            r.Title,
+		Year:             r.Year,
+		Runtime:          r.Runtime,
+		Genres:           r.Genres,
+		Version:          r.Version,
+		OriginalLanguage: r.OriginalLanguage,
+	}
+}
+
+// RevisionChange is one field that differs between two revisions.
+type RevisionChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// Diff returns the fields that changed going from r to to, keyed by their
+// JSON name.
+func (r *MovieRevision) Diff(to *MovieRevision) map[string]RevisionChange {
+	diff := make(map[string]RevisionChange)
+	if r.Title != to.Title {
+		diff["title"] = RevisionChange{From: r.Title, To: to.Title}
+	}
+	if r.Year != to.Year {
+		diff["year"] = RevisionChange{From: r.Year, To: to.Year}
+	}
+	if r.Runtime != to.Runtime {
+		diff["runtime"] = RevisionChange{From: r.Runtime, To: to.Runtime}
+	}
+	if !slices.Equal(r.Genres, to.Genres) {
+		diff["genres"] = RevisionChange{From: r.Genres, To: to.Genres}
+	}
+	if r.OriginalLanguage != to.OriginalLanguage {
+		diff["original_language"] = RevisionChange{From: r.OriginalLanguage, To: to.OriginalLanguage}
+	}
+	return diff
+}
+
+// recordRevision snapshots the current row of a movie into movie_revisions. It
+// runs inside the caller's transaction so the snapshot always matches the
+// write that produced it. The anonymous user is recorded as NULL.
+func recordRevision(ctx context.Context, tx *sql.Tx, movieID, userID int64, operation string) error {
+	query := `
+  INSERT INTO movie_revisions (movie_id,version,user_id,operation,title,year,runtime,genres,original_language)
+  SELECT id,version,NULLIF($2, 0),$3,title,year,runtime,genres,original_language
+  FROM movies
+  WHERE id = $1
+  `
+	_, err := tx.ExecContext(ctx, query, movieID, userID, operation)
+	return err
+}
+
+type MovieRevisionModel struct {
+	DB *sql.DB
+}
+
+// movieRevisionSelect is the column list and source shared by every revision
+// query. Revisions are aliased r and their movie m.
+const movieRevisionSelect = `
+  r.id,r.movie_id,r.version,r.user_id,r.operation,r.created_at,r.title,r.year,r.runtime,r.genres,r.original_language,m.created_at
+  FROM movie_revisions r
+  INNER JOIN movies m ON m.id = r.movie_id`
+
+// fields returns scan destinations matching movieRevisionSelect.
+func (r *MovieRevision) fields() []any {
+	return []any{
+		&r.ID,
+		&r.MovieID,
+		&r.Version,
+		&r.UserID,
+		&r.Operation,
+		&r.CreatedAt,
+		&r.Title,
+		&r.Year,
+		&r.Runtime,
+		pq.Array(&r.Genres),
+		&r.OriginalLanguage,
+		&r.movieCreatedAt,
+	}
+}
+
+func (m MovieRevisionModel) GetAllForMovie(movieID int64, filters Filter) ([]*MovieRevision, PageMetaData, error) {
+	query := fmt.Sprintf(`
+  SELECT count(*) OVER(),%s
+  WHERE r.movie_id = $1
+  ORDER BY r.%s %s, r.id ASC
+  LIMIT $2 OFFSET $3
+  `, movieRevisionSelect, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, PageMetaData{}, err
+	}
+	defer rows.Close()
+
+	revisions := []*MovieRevision{}
+	var totalRecords int
+	for rows.Next() {
+		var revision MovieRevision
+		err := rows.Scan(append([]any{&totalRecords}, revision.fields()...)...)
+		if err != nil {
+			return nil, PageMetaData{}, err
+		}
+		revisions = append(revisions, &revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, PageMetaData{}, err
+	}
+	return revisions, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// GetVersion returns the revision that produced the given version of a movie.
+func (m MovieRevisionModel) GetVersion(movieID int64, version int32) (*MovieRevision, error) {
+	query := fmt.Sprintf(`
+  SELECT %s
+  WHERE r.movie_id = $1 AND r.version = $2
+  `, movieRevisionSelect)
+	return m.getOne(query, movieID, version)
+}
+
+// GetAsOf returns the latest revision of a movie written at or before t.
+func (m MovieRevisionModel) GetAsOf(movieID int64, t time.Time) (*MovieRevision, error) {
+	query := fmt.Sprintf(`
+  SELECT %s
+  WHERE r.movie_id = $1 AND r.created_at <= $2
+  ORDER BY r.version DESC
+  LIMIT 1
+  `, movieRevisionSelect)
+	return m.getOne(query, movieID, t)
+}
+
+func (m MovieRevisionModel) getOne(query string, args ...any) (*MovieRevision, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var revision MovieRevision
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(revision.fields()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	return &revision, nil
+}
@@ -3,11 +3,17 @@ package data
 import (
 	"errors"
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
-var ErrInvalidRuntimeFromat = errors.New("the provided string is no")
+var ErrInvalidRuntimeFormat = errors.New(`must be a whole number of minutes, such as 102, "102 mins", "1h 42m" or "PT1H42M"`)
+
+// RuntimeFormats are the ways a Runtime can be written out. "mins" is the
+// default "102 mins"; "minutes" is a bare number, "hm" is "1h 42m" and
+// "iso8601" is "PT1H42M".
+var RuntimeFormats = []string{"mins", "minutes", "hm", "iso8601"}
 
 type Runtime int32
 
@@ -16,22 +22,93 @@ func (rt Runtime) MarshalJSON() ([]byte, error) {
 	quoteJsonValue := strconv.Quote(jsonValue)
 	return []byte(quoteJsonValue), nil
 }
+
+// UnmarshalJSON accepts a JSON number of minutes or any string ParseRuntime
+// understands.
 func (rt *Runtime) UnmarshalJSON(jsonValue []byte) error {
-	unquottedString, err := strconv.Unquote(string(jsonValue))
+	s := string(jsonValue)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	runtime, err := ParseRuntime(s)
 	if err != nil {
-		return ErrInvalidRuntimeFromat
+		return err
 	}
+	*rt = runtime
+	return nil
+}
+
+var (
+	runtimeMinsRX = regexp.MustCompile(`^(\d+)\s*(?:mins?|minutes?)?$`)
+	runtimeHMRX   = regexp.MustCompile(`^(?:(\d+)\s*h)?\s*(?:(\d+)\s*m)?$`)
+	runtimeISORX  = regexp.MustCompile(`^pt(?:(\d+)h)?(?:(\d+)m)?$`)
+)
 
-	parts := strings.Split(unquottedString, " ")
-	// Check for sanity of the thing
-	if len(parts) != 2 || parts[1] != "mins" {
-		return ErrInvalidRuntimeFromat
+// ParseRuntime reads a runtime written as a number of minutes ("102",
+// "102 mins"), hours and minutes ("1h 42m", "2h") or an ISO 8601 duration
+// ("PT102M", "PT1H42M"). It is shared with query string parameters such as
+// runtime_min.
+func ParseRuntime(s string) (Runtime, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	var hours, minutes string
+	if m := runtimeMinsRX.FindStringSubmatch(s); m != nil {
+		minutes = m[1]
+	} else if m := runtimeHMRX.FindStringSubmatch(s); m != nil && s != "" {
+		hours, minutes = m[1], m[2]
+	} else if m := runtimeISORX.FindStringSubmatch(s); m != nil && s != "pt" {
+		hours, minutes = m[1], m[2]
+	} else {
+		return 0, ErrInvalidRuntimeFormat
 	}
 
-	i, err := strconv.ParseInt(parts[0], 10, 64)
-	if err != nil {
-		return ErrInvalidRuntimeFromat
+	total := int64(0)
+	for _, part := range []struct {
+		value string
+		scale int64
+	}{{hours, 60}, {minutes, 1}} {
+		if part.value == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(part.value, 10, 32)
+		if err != nil {
+			return 0, ErrInvalidRuntimeFormat
+		}
+		total += n * part.scale
+	}
+	if total > 1<<31-1 {
+		return 0, ErrInvalidRuntimeFormat
+	}
+	return Runtime(total), nil
+}
+
+// Format returns the runtime in one of RuntimeFormats, as a value ready to
+// be encoded to JSON. Unknown formats fall back to "mins".
+func (rt Runtime) Format(format string) any {
+	hours, minutes := rt/60, rt%60
+	switch format {
+	case "minutes":
+		return int32(rt)
+	case "hm":
+		switch {
+		case hours == 0:
+			return fmt.Sprintf("%dm", minutes)
+		case minutes == 0:
+			return fmt.Sprintf("%dh", hours)
+		default:
+			return fmt.Sprintf("%dh %dm", hours, minutes)
+		}
+	case "iso8601":
+		switch {
+		case hours == 0:
+			return fmt.Sprintf("PT%dM", minutes)
+		case minutes == 0:
+			return fmt.Sprintf("PT%dH", hours)
+		default:
+			return fmt.Sprintf("PT%dH%dM", hours, minutes)
+		}
+	default:
+		return fmt.Sprintf("%d mins", rt)
 	}
-	*rt = Runtime(i)
-	return nil
 }
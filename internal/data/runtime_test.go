@@ -0,0 +1,154 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseRuntime(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Runtime
+		wantErr bool
+	}{
+		{input: "102", want: 102},
+		{input: "0", want: 0},
+		{input: "102 mins", want: 102},
+		{input: "102mins", want: 102},
+		{input: "1 min", want: 1},
+		{input: "102 minutes", want: 102},
+		{input: "  102 MINS  ", want: 102},
+		{input: "1h 42m", want: 102},
+		{input: "1h42m", want: 102},
+		{input: "2h", want: 120},
+		{input: "42m", want: 42},
+		{input: "1 h 42 m", want: 102},
+		{input: "PT1H42M", want: 102},
+		{input: "pt102m", want: 102},
+		{input: "PT2H", want: 120},
+		{input: "PT42M", want: 42},
+		{input: "2147483647", want: 1<<31 - 1},
+		{input: "", wantErr: true},
+		{input: "PT", wantErr: true},
+		{input: "-5", wantErr: true},
+		{input: "1.5", wantErr: true},
+		{input: "102 secs", wantErr: true},
+		{input: "42m 1h", wantErr: true},
+		{input: "PT1M42H", wantErr: true},
+		{input: "P1D", wantErr: true},
+		{input: "2147483648", wantErr: true},
+		{input: "35791395h", wantErr: true},
+		{input: "99999999999999999999", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRuntime(tt.input)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidRuntimeFormat) {
+				t.Errorf("ParseRuntime(%q) = %d, %v; want ErrInvalidRuntimeFormat", tt.input, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseRuntime(%q) = %d, %v; want %d", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestRuntimeUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Runtime
+		wantErr bool
+	}{
+		{input: `102`, want: 102},
+		{input: `"102 mins"`, want: 102},
+		{input: `"1h 42m"`, want: 102},
+		{input: `"PT1H42M"`, want: 102},
+		{input: `102.5`, wantErr: true},
+		{input: `true`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var got Runtime
+		err := json.Unmarshal([]byte(tt.input), &got)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("unmarshalling %s = %d, %v; want %d, error %t", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRuntimeFormat(t *testing.T) {
+	tests := []struct {
+		runtime Runtime
+		format  string
+		want    any
+	}{
+		{102, "mins", "102 mins"},
+		{102, "", "102 mins"},
+		{102, "unknown", "102 mins"},
+		{102, "minutes", int32(102)},
+		{102, "hm", "1h 42m"},
+		{120, "hm", "2h"},
+		{42, "hm", "42m"},
+		{0, "hm", "0m"},
+		{102, "iso8601", "PT1H42M"},
+		{120, "iso8601", "PT2H"},
+		{42, "iso8601", "PT42M"},
+		{0, "iso8601", "PT0M"},
+	}
+	for _, tt := range tests {
+		if got := tt.runtime.Format(tt.format); got != tt.want {
+			t.Errorf("Runtime(%d).Format(%q) = %#v, want %#v", tt.runtime, tt.format, got, tt.want)
+		}
+	}
+}
+
+// Every format ParseRuntime is given back by Format must read as the same
+// runtime.
+func TestRuntimeFormatRoundTrip(t *testing.T) {
+	for _, runtime := range []Runtime{0, 1, 59, 60, 61, 102, 120, 600} {
+		for _, format := range RuntimeFormats {
+			js, err := json.Marshal(runtime.Format(format))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got Runtime
+			if err := json.Unmarshal(js, &got); err != nil || got != runtime {
+				t.Errorf("Runtime(%d) in %s is %s, which reads back as %d, %v", runtime, format, js, got, err)
+			}
+		}
+	}
+}
+
+func TestMovieMarshalJSONRuntimeFormat(t *testing.T) {
+	movie := &Movie{Id: 1, Title: "Heat", Year: 1995, Runtime: 170}
+	for format, want := range map[string]string{"": `"170 mins"`, "minutes": `170`, "hm": `"2h 50m"`, "iso8601": `"PT2H50M"`} {
+		movie.RuntimeFormat = format
+		for name, v := range map[string]any{
+			"movie":         movie,
+			"similar movie": &SimilarMovie{Movie: movie, Score: 0.5},
+			"revision":      &MovieRevision{MovieID: 1, Title: "Heat", Runtime: 170, RuntimeFormat: format},
+		} {
+			js, err := json.Marshal(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var object map[string]json.RawMessage
+			if err := json.Unmarshal(js, &object); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(object["runtime"]); got != want {
+				t.Errorf("%s with format %q has runtime %s, want %s", name, format, got, want)
+			}
+			if _, ok := object["RuntimeFormat"]; ok {
+				t.Errorf("%s encodes RuntimeFormat: %s", name, js)
+			}
+		}
+	}
+	js, _ := json.Marshal(&SimilarMovie{Movie: movie, Score: 0.5})
+	var similar map[string]any
+	json.Unmarshal(js, &similar)
+	if similar["score"] != 0.5 || similar["title"] != "Heat" {
+		t.Errorf("similar movie encodes as %s, want its title and score", js)
+	}
+}
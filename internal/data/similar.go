@@ -0,0 +1,172 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"cinlim.bikraj.net/internal/validator"
+	"github.com/lib/pq"
+)
+
+// SimilarityWeights sets how much each kind of overlap counts towards the
+// score of a similar movie. Each part of the score is between 0 and 1, so the
+// weights are relative to one another.
+type SimilarityWeights struct {
+	Genres  float32 `json:"genres"`
+	Year    float32 `json:"year"`
+	Runtime float32 `json:"runtime"`
+	Title   float32 `json:"title"`
+	Version int32   `json:"version"`
+}
+
+func ValidateSimilarityWeights(v *validator.Validator, weights *SimilarityWeights) {
+	v.Check(weights.Genres >= 0, "genres", "must not be negative")
+	v.Check(weights.Year >= 0, "year", "must not be negative")
+	v.Check(weights.Runtime >= 0, "runtime", "must not be negative")
+	v.Check(weights.Title >= 0, "title", "must not be negative")
+	v.Check(weights.Genres+weights.Year+weights.Runtime+weights.Title > 0, "weights", "must not all be zero")
+}
+
+type SimilarityWeightsModel struct {
+	DB *sql.DB
+}
+
+func (m SimilarityWeightsModel) Get() (*SimilarityWeights, error) {
+	query := `
+  SELECT genres,year,runtime,title,version
+  FROM similarity_weights
+  WHERE id = 1
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var weights SimilarityWeights
+	err := m.DB.QueryRowContext(ctx, query).Scan(&weights.Genres, &weights.Year, &weights.Runtime, &weights.Title, &weights.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	return &weights, nil
+}
+
+func (m SimilarityWeightsModel) Update(weights *SimilarityWeights) error {
+	query := `
+  UPDATE similarity_weights
+  SET genres = $1, year = $2, runtime = $3, title = $4, version = version + 1
+  WHERE id = 1 AND version = $5
+  RETURNING version
+  `
+	args := []any{weights.Genres, weights.Year, weights.Runtime, weights.Title, weights.Version}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&weights.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// SimilarMovie is a movie with its similarity score to another.
+type SimilarMovie struct {
+	*Movie
+	Score float32 `json:"score"`
+}
+
+// MarshalJSON adds the score to the movie, which would otherwise be dropped
+// in favour of the embedded Movie's MarshalJSON, and formats the runtime as
+// Movie.MarshalJSON does.
+func (s SimilarMovie) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		*movieJSON
+		Runtime any     `json:"runtime"`
+		Score   float32 `json:"score"`
+	}{(*movieJSON)(s.Movie), s.Runtime.Format(s.RuntimeFormat), s.Score})
+}
+
+// GetSimilar ranks live movies by how much they have in common with the
+// movie id, using the current SimilarityWeights:
+//
+//   - genres: Jaccard overlap of the genre sets
+//   - year: 1 / (1 + years apart / 5)
+//   - runtime: 1 / (1 + minutes apart / 15)
+//   - title: share of the movie's title terms, stop words removed, that the
+//     other title also has
+//
+// Only movies sharing at least one genre are considered, which lets the &&
+// condition use movies_genres_idx. Paging comes from filters.
+func (m MovieModel) GetSimilar(id int64, filters Filter) ([]*SimilarMovie, PageMetaData, error) {
+	query := `
+  WITH target AS (
+    SELECT id,genres,year,runtime,tsvector_to_array(to_tsvector('english', title)) AS terms
+    FROM movies
+    WHERE id = $1 AND deleted_at IS NULL
+  )
+  SELECT count(*) OVER(),id,created_at,title,year,runtime,genres,version,average_rating,rating_count,original_language,score
+  FROM (
+    SELECT m.*, (
+      w.genres * cardinality(ARRAY(SELECT unnest(m.genres) INTERSECT SELECT unnest(t.genres)))::real
+        / cardinality(ARRAY(SELECT unnest(m.genres) UNION SELECT unnest(t.genres)))
+      + w.year / (1 + abs(m.year - t.year) / 5.0)
+      + w.runtime / (1 + abs(m.runtime - t.runtime) / 15.0)
+      + w.title * cardinality(ARRAY(
+          SELECT unnest(tsvector_to_array(to_tsvector('english', m.title))) INTERSECT SELECT unnest(t.terms)
+        ))::real / greatest(cardinality(t.terms), 1)
+    )::real AS score
+    FROM movies m
+    CROSS JOIN target t
+    CROSS JOIN similarity_weights w
+    WHERE m.deleted_at IS NULL AND m.id <> t.id AND m.genres && t.genres
+  ) AS similar
+  ORDER BY score DESC, id ASC
+  LIMIT $2 OFFSET $3
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, id, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, PageMetaData{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	movies := []*SimilarMovie{}
+	for rows.Next() {
+		similar := SimilarMovie{Movie: &Movie{}}
+		err := rows.Scan(
+			&totalRecords,
+			&similar.Id,
+			&similar.CreatedAt,
+			&similar.Title,
+			&similar.Year,
+			&similar.Runtime,
+			pq.Array(&similar.Genres),
+			&similar.Version,
+			&similar.AverageRating,
+			&similar.RatingCount,
+			&similar.OriginalLanguage,
+			&similar.Score,
+		)
+		if err != nil {
+			return nil, PageMetaData{}, err
+		}
+		movies = append(movies, &similar)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, PageMetaData{}, err
+	}
+	return movies, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
@@ -0,0 +1,53 @@
+package data
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Suggestion is a title offered while the user is still typing.
+type Suggestion struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+	Year  int32  `json:"year"`
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Suggest returns up to limit live movies whose titles start with q, followed
+// by titles that are merely similar to it, so "inter" finds "Interstellar"
+// and "intersteller" still does. Prefix matches use movies_title_prefix_idx
+// and similar ones movies_title_trgm_idx.
+func (m MovieModel) Suggest(q string, limit int) ([]*Suggestion, error) {
+	query := `
+  SELECT id,title,year
+  FROM movies
+  WHERE deleted_at IS NULL AND (lower(title) LIKE $1 OR title % $2)
+  ORDER BY lower(title) LIKE $1 DESC, similarity(title, $2) DESC, title, id
+  LIMIT $3
+  `
+	prefix := likeEscaper.Replace(strings.ToLower(q)) + "%"
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, prefix, q, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []*Suggestion{}
+	for rows.Next() {
+		var suggestion Suggestion
+		err := rows.Scan(&suggestion.ID, &suggestion.Title, &suggestion.Year)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, &suggestion)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,187 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"cinlim.bikraj.net/internal/validator"
+	"github.com/lib/pq"
+)
+
+// LanguageRX matches a lowercased BCP 47 language tag such as "fr" or "pt-br".
+var LanguageRX = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// MovieTitle is the title a movie goes by in one language.
+type MovieTitle struct {
+	MovieID  int64  `json:"movie_id"`
+	Language string `json:"language"`
+	Title    string `json:"title"`
+}
+
+func ValidateMovieTitle(v *validator.Validator, title *MovieTitle) {
+	v.Check(validator.Matches(title.Language, LanguageRX), "language", "must be a language tag such as fr or pt-br")
+	v.Check(title.Title != "", "title", "must be provided")
+	v.Check(len(title.Title) <= 500, "title", "must not be more than 500 bytes long")
+}
+
+type MovieTitleModel struct {
+	DB *sql.DB
+}
+
+// Upsert sets the movie's title in a language, replacing any existing one.
+func (m MovieTitleModel) Upsert(title *MovieTitle) error {
+	query := `
+  INSERT INTO movie_titles (movie_id,language,title)
+  VALUES ($1,$2,$3)
+  ON CONFLICT (movie_id, language) DO UPDATE SET title = EXCLUDED.title
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, title.MovieID, title.Language, title.Title)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrNoRecordFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m MovieTitleModel) Delete(movieID int64, language string) error {
+	query := `
+  DELETE FROM movie_titles WHERE movie_id = $1 AND language = $2
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, language)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+	return nil
+}
+
+func (m MovieTitleModel) GetForMovie(movieID int64) ([]*MovieTitle, error) {
+	query := `
+  SELECT movie_id,language,title
+  FROM movie_titles
+  WHERE movie_id = $1
+  ORDER BY language
+  `
+	return m.query(query, movieID)
+}
+
+// Localize swaps each movie's title for its title in the most preferred of
+// languages, keeping the original in OriginalTitle. A movie's own title
+// counts as its title in its original_language, so it is kept, with
+// TitleLanguage set, when that language outranks every alternate title. An
+// alternate title in the original language takes precedence over the
+// original. Movies with no title in any of the languages are left alone.
+func (m MovieTitleModel) Localize(movies []*Movie, languages []string) error {
+	if len(movies) == 0 || len(languages) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.Id
+	}
+	query := `
+  SELECT movie_id,language,title,false AS original
+  FROM movie_titles
+  WHERE movie_id = ANY($1) AND language = ANY($2)
+  UNION ALL
+  SELECT id,original_language,title,true
+  FROM movies
+  WHERE id = ANY($1) AND original_language = ANY($2)
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids), pq.Array(languages))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// best holds, per movie, the preferred title found so far and its rank.
+	type choice struct {
+		title    MovieTitle
+		original bool
+		rank     int
+	}
+	best := make(map[int64]choice)
+	for rows.Next() {
+		var c choice
+		err := rows.Scan(&c.title.MovieID, &c.title.Language, &c.title.Title, &c.original)
+		if err != nil {
+			return err
+		}
+		c.rank = len(languages)
+		for i, language := range languages {
+			if strings.EqualFold(language, c.title.Language) {
+				c.rank = i
+				break
+			}
+		}
+		current, ok := best[c.title.MovieID]
+		if !ok || c.rank < current.rank || (c.rank == current.rank && current.original) {
+			best[c.title.MovieID] = c
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		c, ok := best[movie.Id]
+		if !ok {
+			continue
+		}
+		if !c.original {
+			movie.OriginalTitle = movie.Title
+			movie.Title = c.title.Title
+		}
+		movie.TitleLanguage = c.title.Language
+	}
+	return nil
+}
+
+func (m MovieTitleModel) query(query string, args ...any) ([]*MovieTitle, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	titles := []*MovieTitle{}
+	for rows.Next() {
+		var title MovieTitle
+		err := rows.Scan(&title.MovieID, &title.Language, &title.Title)
+		if err != nil {
+			return nil, err
+		}
+		titles = append(titles, &title)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return titles, nil
+}
@@ -0,0 +1,206 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"cinlim.bikraj.net/internal/validator"
+	"github.com/lib/pq"
+)
+
+var ErrDuplicateWatchlistEntry = errors.New("movie already on watchlist")
+
+// WatchlistEntry is one movie on a user's watchlist. Position is the user's
+// own ordering; Priority runs from 1 (watch first) to 5.
+type WatchlistEntry struct {
+	MovieID  int64     `json:"movie_id"`
+	Priority int32     `json:"priority"`
+	Note     string    `json:"note"`
+	Position int32     `json:"position"`
+	AddedAt  time.Time `json:"added_at"`
+	Movie    *Movie    `json:"movie,omitempty"`
+}
+
+func ValidateWatchlistEntry(v *validator.Validator, entry *WatchlistEntry) {
+	v.Check(entry.MovieID > 0, "movie_id", "must be provided")
+	v.Check(entry.Priority >= 1 && entry.Priority <= 5, "priority", "must be between 1 and 5")
+	v.Check(len(entry.Note) <= 1000, "note", "must not be more than 1000 bytes long")
+}
+
+type WatchlistModel struct {
+	DB *sql.DB
+}
+
+// Add puts a movie at the end of the user's watchlist.
+func (m WatchlistModel) Add(userID int64, entry *WatchlistEntry) error {
+	query := `
+  INSERT INTO watchlist_entries (user_id,movie_id,priority,note,position)
+  SELECT $1,$2,$3,$4,COALESCE(MAX(position), 0) + 1
+  FROM watchlist_entries
+  WHERE user_id = $1
+  RETURNING position,added_at
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID, entry.MovieID, entry.Priority, entry.Note).Scan(&entry.Position, &entry.AddedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateWatchlistEntry
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m WatchlistModel) Get(userID, movieID int64) (*WatchlistEntry, error) {
+	query := `
+  SELECT movie_id,priority,note,position,added_at
+  FROM watchlist_entries
+  WHERE user_id = $1 AND movie_id = $2
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var entry WatchlistEntry
+	err := m.DB.QueryRowContext(ctx, query, userID, movieID).Scan(&entry.MovieID, &entry.Priority, &entry.Note, &entry.Position, &entry.AddedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	return &entry, nil
+}
+
+// Update saves the priority and note of an entry.
+func (m WatchlistModel) Update(userID int64, entry *WatchlistEntry) error {
+	query := `
+  UPDATE watchlist_entries
+  SET priority = $3, note = $4
+  WHERE user_id = $1 AND movie_id = $2
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, entry.MovieID, entry.Priority, entry.Note)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+	return nil
+}
+
+func (m WatchlistModel) Remove(userID, movieID int64) error {
+	query := `
+  DELETE FROM watchlist_entries
+  WHERE user_id = $1 AND movie_id = $2
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, movieID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+	return nil
+}
+
+// Reorder moves the given movies to the top of the watchlist in the order
+// listed. Entries that are not mentioned keep their relative order after them.
+func (m WatchlistModel) Reorder(userID int64, movieIDs []int64) error {
+	query := `
+  UPDATE watchlist_entries w
+  SET position = ranked.position
+  FROM (
+    SELECT e.movie_id, row_number() OVER (ORDER BY o.ord NULLS LAST, e.position) AS position
+    FROM watchlist_entries e
+    LEFT JOIN unnest($2::bigint[]) WITH ORDINALITY AS o(movie_id, ord) ON o.movie_id = e.movie_id
+    WHERE e.user_id = $1
+  ) AS ranked
+  WHERE w.user_id = $1 AND w.movie_id = ranked.movie_id
+  `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, pq.Array(movieIDs))
+	return err
+}
+
+// GetAllForUser lists a user's watchlist with each movie embedded. Movies in
+// the trash are left out.
+func (m WatchlistModel) GetAllForUser(userID int64, filters Filter) ([]*WatchlistEntry, PageMetaData, error) {
+	query := fmt.Sprintf(`
+  SELECT count(*) OVER(),w.movie_id,w.priority,w.note,w.position,w.added_at,
+    m.created_at,m.title,m.year,m.runtime,m.genres,m.version,m.average_rating,m.rating_count,m.original_language
+  FROM watchlist_entries w
+  INNER JOIN movies m ON m.id = w.movie_id
+  WHERE w.user_id = $1 AND m.deleted_at IS NULL
+  ORDER BY %s %s, w.position ASC
+  LIMIT $2 OFFSET $3
+  `, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, PageMetaData{}, err
+	}
+	defer rows.Close()
+
+	entries := []*WatchlistEntry{}
+	var totalRecords int
+	for rows.Next() {
+		var entry WatchlistEntry
+		var movie Movie
+		err := rows.Scan(
+			&totalRecords,
+			&entry.MovieID,
+			&entry.Priority,
+			&entry.Note,
+			&entry.Position,
+			&entry.AddedAt,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Version,
+			&movie.AverageRating,
+			&movie.RatingCount,
+			&movie.OriginalLanguage,
+		)
+		if err != nil {
+			return nil, PageMetaData{}, err
+		}
+		movie.Id = entry.MovieID
+		entry.Movie = &movie
+		entries = append(entries, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, PageMetaData{}, err
+	}
+	return entries, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
@@ -0,0 +1,221 @@
+// Package jsonpatch applies JSON Merge Patch (RFC 7396) and the add, remove,
+// replace and test operations of JSON Patch (RFC 6902) to documents decoded
+// with encoding/json into plain maps, slices and scalars.
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var ErrTestFailed = errors.New("test operation failed")
+
+// Operation is a single JSON Patch operation.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+	// From is only used by move and copy, which are not supported, but is
+	// accepted so those operations fail with a clear error.
+	From string `json:"from"`
+}
+
+// MergePatch applies patch to doc as described in RFC 7396: objects are
+// merged key by key, null removes a key and anything else replaces the
+// target outright.
+func MergePatch(doc, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	docObject, ok := doc.(map[string]any)
+	if !ok {
+		docObject = map[string]any{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(docObject, key)
+			continue
+		}
+		docObject[key] = MergePatch(docObject[key], value)
+	}
+	return docObject
+}
+
+// Apply runs ops against doc in order and returns the patched document. It
+// stops at the first operation that fails; ErrTestFailed is returned when a
+// test operation does not match.
+func Apply(doc any, ops []Operation) (any, error) {
+	for i, op := range ops {
+		tokens, err := parsePointer(op.Path)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+
+		var value any
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fmt.Errorf("operation %d: %s requires a value", i, op.Op)
+			}
+			err = json.Unmarshal(op.Value, &value)
+			if err != nil {
+				return nil, fmt.Errorf("operation %d: invalid value: %w", i, err)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("operation %d: unsupported op %q", i, op.Op)
+		}
+
+		switch op.Op {
+		case "test":
+			current, err := get(doc, tokens)
+			if err != nil {
+				return nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+			if !reflect.DeepEqual(current, value) {
+				return nil, fmt.Errorf("operation %d: %w at %q", i, ErrTestFailed, op.Path)
+			}
+		default:
+			doc, err = apply(doc, tokens, op.Op, value)
+			if err != nil {
+				return nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+		}
+	}
+	return doc, nil
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into unescaped tokens.
+func parsePointer(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path %q must start with /", path)
+	}
+	tokens := strings.Split(path[1:], "/")
+	for i, token := range tokens {
+		token = strings.ReplaceAll(token, "~1", "/")
+		tokens[i] = strings.ReplaceAll(token, "~0", "~")
+	}
+	return tokens, nil
+}
+
+func get(doc any, tokens []string) (any, error) {
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path member %q does not exist", token)
+			}
+			doc = value
+		case []any:
+			i, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("path member %q does not exist", token)
+		}
+	}
+	return doc, nil
+}
+
+// apply performs add, remove or replace at tokens and returns doc with the
+// change made, since changing an array's length gives a new slice.
+func apply(doc any, tokens []string, op string, value any) (any, error) {
+	if len(tokens) == 0 {
+		if op == "remove" {
+			return nil, errors.New("cannot remove the whole document")
+		}
+		return value, nil
+	}
+	token, rest := tokens[0], tokens[1:]
+
+	switch node := doc.(type) {
+	case map[string]any:
+		child, ok := node[token]
+		if len(rest) > 0 {
+			if !ok {
+				return nil, fmt.Errorf("path member %q does not exist", token)
+			}
+			child, err := apply(child, rest, op, value)
+			if err != nil {
+				return nil, err
+			}
+			node[token] = child
+			return node, nil
+		}
+		switch {
+		case op == "add":
+			node[token] = value
+		case !ok:
+			return nil, fmt.Errorf("path member %q does not exist", token)
+		case op == "remove":
+			delete(node, token)
+		default:
+			node[token] = value
+		}
+		return node, nil
+
+	case []any:
+		if len(rest) > 0 {
+			i, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			node[i], err = apply(node[i], rest, op, value)
+			if err != nil {
+				return nil, err
+			}
+			return node, nil
+		}
+		switch op {
+		case "add":
+			i := len(node)
+			if token != "-" {
+				var err error
+				i, err = arrayIndex(token, len(node))
+				if err != nil {
+					return nil, err
+				}
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+		case "remove":
+			i, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node[:i], node[i+1:]...)
+		default:
+			i, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			node[i] = value
+		}
+		return node, nil
+
+	default:
+		return nil, fmt.Errorf("path member %q does not exist", token)
+	}
+}
+
+// arrayIndex parses an array index token, which must be between 0 and last.
+// RFC 6901 only allows plain digits, so signs and leading zeros are refused.
+func arrayIndex(token string, last int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || token[0] < '0' || token[0] > '9' || i > last || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("array index %q is out of range", token)
+	}
+	return i, nil
+}
@@ -0,0 +1,230 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func decode(t *testing.T, s string) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("decoding %s: %v", s, err)
+	}
+	return v
+}
+
+// The examples from RFC 7396 Appendix A.
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		doc, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		got := MergePatch(decode(t, tt.doc), decode(t, tt.patch))
+		if want := decode(t, tt.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("MergePatch(%s, %s) = %v, want %v", tt.doc, tt.patch, got, want)
+		}
+	}
+}
+
+// Mostly the examples from RFC 6902 Appendix A, limited to the operations
+// the package supports.
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc, ops string
+		want     string
+		wantErr  bool
+		testFail bool
+	}{
+		{
+			name: "add an object member",
+			doc:  `{"foo":"bar"}`,
+			ops:  `[{"op":"add","path":"/baz","value":"qux"}]`,
+			want: `{"baz":"qux","foo":"bar"}`,
+		},
+		{
+			name: "add an array element",
+			doc:  `{"foo":["bar","baz"]}`,
+			ops:  `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			want: `{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			name: "remove an object member",
+			doc:  `{"baz":"qux","foo":"bar"}`,
+			ops:  `[{"op":"remove","path":"/baz"}]`,
+			want: `{"foo":"bar"}`,
+		},
+		{
+			name: "remove an array element",
+			doc:  `{"foo":["bar","qux","baz"]}`,
+			ops:  `[{"op":"remove","path":"/foo/1"}]`,
+			want: `{"foo":["bar","baz"]}`,
+		},
+		{
+			name: "replace a value",
+			doc:  `{"baz":"qux","foo":"bar"}`,
+			ops:  `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			want: `{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			name: "test a value",
+			doc:  `{"baz":"qux","foo":["a",2,"c"]}`,
+			ops:  `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			want: `{"baz":"qux","foo":["a",2,"c"]}`,
+		},
+		{
+			name:     "test a value that differs",
+			doc:      `{"baz":"qux"}`,
+			ops:      `[{"op":"test","path":"/baz","value":"bar"}]`,
+			wantErr:  true,
+			testFail: true,
+		},
+		{
+			name: "add a nested member object",
+			doc:  `{"foo":"bar"}`,
+			ops:  `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`,
+			want: `{"foo":"bar","child":{"grandchild":{}}}`,
+		},
+		{
+			name:    "add to a nonexistent target",
+			doc:     `{"foo":"bar"}`,
+			ops:     `[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			wantErr: true,
+		},
+		{
+			name: "escape ordering",
+			doc:  `{"/":9,"~1":10}`,
+			ops:  `[{"op":"test","path":"/~01","value":10},{"op":"test","path":"/~1","value":9}]`,
+			want: `{"/":9,"~1":10}`,
+		},
+		{
+			name:     "compare strings and numbers",
+			doc:      `{"/":9,"~1":10}`,
+			ops:      `[{"op":"test","path":"/~01","value":"10"}]`,
+			wantErr:  true,
+			testFail: true,
+		},
+		{
+			name: "add an array value",
+			doc:  `{"foo":["bar"]}`,
+			ops:  `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			want: `{"foo":["bar",["abc","def"]]}`,
+		},
+		{
+			name: "add at the end of an array by index",
+			doc:  `{"foo":["bar"]}`,
+			ops:  `[{"op":"add","path":"/foo/1","value":"baz"}]`,
+			want: `{"foo":["bar","baz"]}`,
+		},
+		{
+			name: "replace the whole document",
+			doc:  `{"foo":"bar"}`,
+			ops:  `[{"op":"replace","path":"","value":{"baz":"qux"}}]`,
+			want: `{"baz":"qux"}`,
+		},
+		{
+			name:    "remove the whole document",
+			doc:     `{"foo":"bar"}`,
+			ops:     `[{"op":"remove","path":""}]`,
+			wantErr: true,
+		},
+		{
+			name:    "remove a missing member",
+			doc:     `{"foo":"bar"}`,
+			ops:     `[{"op":"remove","path":"/baz"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "replace past the end of an array",
+			doc:     `{"foo":["bar"]}`,
+			ops:     `[{"op":"replace","path":"/foo/1","value":"baz"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "array index with a leading zero",
+			doc:     `{"foo":["bar","baz"]}`,
+			ops:     `[{"op":"remove","path":"/foo/01"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "array index with a sign",
+			doc:     `{"foo":["bar","baz"]}`,
+			ops:     `[{"op":"remove","path":"/foo/+1"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "remove the - index",
+			doc:     `{"foo":["bar"]}`,
+			ops:     `[{"op":"remove","path":"/foo/-"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "path without a leading slash",
+			doc:     `{"foo":"bar"}`,
+			ops:     `[{"op":"replace","path":"foo","value":1}]`,
+			wantErr: true,
+		},
+		{
+			name:    "add without a value",
+			doc:     `{"foo":"bar"}`,
+			ops:     `[{"op":"add","path":"/baz"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "unsupported operation",
+			doc:     `{"foo":"bar"}`,
+			ops:     `[{"op":"move","from":"/foo","path":"/baz"}]`,
+			wantErr: true,
+		},
+		{
+			name:     "operations stop at a failed test",
+			doc:      `{"foo":"bar"}`,
+			ops:      `[{"op":"test","path":"/foo","value":"baz"},{"op":"remove","path":"/foo"}]`,
+			wantErr:  true,
+			testFail: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ops []Operation
+			if err := json.Unmarshal([]byte(tt.ops), &ops); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Apply(decode(t, tt.doc), ops)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Apply returned %v, want an error", got)
+				}
+				if errors.Is(err, ErrTestFailed) != tt.testFail {
+					t.Fatalf("errors.Is(%v, ErrTestFailed) = %t, want %t", err, !tt.testFail, tt.testFail)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := decode(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("Apply = %v, want %v", got, want)
+			}
+		})
+	}
+}
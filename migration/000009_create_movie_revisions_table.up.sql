@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS movie_revisions (
+  id bigserial PRIMARY KEY,
+  movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  version integer NOT NULL,
+  user_id bigint REFERENCES users ON DELETE SET NULL,
+  operation text NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  title text NOT NULL,
+  year integer NOT NULL,
+  runtime integer NOT NULL,
+  genres text[] NOT NULL,
+  UNIQUE (movie_id, version)
+);
+
+-- Seed a first revision for movies that existed before history was kept.
+INSERT INTO movie_revisions (movie_id, version, operation, created_at, title, year, runtime, genres)
+SELECT id, version, 'insert', created_at, title, year, runtime, genres
+FROM movies;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS watchlist_entries (
+  user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+  movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  priority integer NOT NULL DEFAULT 3 CHECK (priority BETWEEN 1 AND 5),
+  note text NOT NULL DEFAULT '',
+  position integer NOT NULL,
+  added_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (user_id, movie_id)
+);
+CREATE INDEX IF NOT EXISTS watchlist_entries_movie_id_idx ON watchlist_entries (movie_id);
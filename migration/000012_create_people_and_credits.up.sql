@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS people (
+  id bigserial PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  name text NOT NULL,
+  birth_year integer NOT NULL DEFAULT 0,
+  biography text NOT NULL DEFAULT '',
+  version integer NOT NULL DEFAULT 1
+);
+CREATE INDEX IF NOT EXISTS people_name_idx ON people (name);
+
+CREATE TABLE IF NOT EXISTS credits (
+  id bigserial PRIMARY KEY,
+  movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  person_id bigint NOT NULL REFERENCES people ON DELETE CASCADE,
+  role text NOT NULL CHECK (role IN ('director', 'writer', 'actor')),
+  character_name text NOT NULL DEFAULT '',
+  UNIQUE (movie_id, person_id, role, character_name)
+);
+CREATE INDEX IF NOT EXISTS credits_person_id_idx ON credits (person_id, role);
+
+INSERT INTO permissions (code) VALUES
+('people:read'), ('people:write');
+
+-- Anyone who could read or write movies gets the matching people permission.
+INSERT INTO users_permissions (user_id, permission_id)
+SELECT up.user_id, np.id
+FROM users_permissions up
+INNER JOIN permissions op ON op.id = up.permission_id
+INNER JOIN permissions np ON np.code = replace(op.code, 'movies:', 'people:')
+WHERE op.code IN ('movies:read', 'movies:write')
+ON CONFLICT DO NOTHING;
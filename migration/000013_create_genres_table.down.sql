@@ -0,0 +1,5 @@
+DELETE FROM permissions WHERE code = 'genres:write';
+ALTER TABLE movies DROP CONSTRAINT genres_length_check;
+ALTER TABLE movies ADD CONSTRAINT genres_length_check CHECK (array_length(genres, 1) BETWEEN 1 AND 5);
+DROP TABLE IF EXISTS genre_aliases;
+DROP TABLE IF EXISTS genres;
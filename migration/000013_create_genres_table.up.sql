@@ -0,0 +1,71 @@
+CREATE TABLE IF NOT EXISTS genres (
+  id bigserial PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  slug text NOT NULL UNIQUE,
+  name text NOT NULL,
+  version integer NOT NULL DEFAULT 1
+);
+
+-- Every spelling that should resolve to a genre, folded the same way as
+-- data.GenreKey. Each genre's own slug is one of its aliases.
+CREATE TABLE IF NOT EXISTS genre_aliases (
+  alias text PRIMARY KEY,
+  genre_id bigint NOT NULL REFERENCES genres ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS genre_aliases_genre_id_idx ON genre_aliases (genre_id);
+
+-- genre_slug folds a genre spelling the same way as data.GenreKey. A
+-- spelling with letters or digits but none from a-z or 0-9, such as
+-- "ドラマ", would fold to nothing, so it gets a slug made from its hash and
+-- keeps its spelling as the display name. Only spellings of bare
+-- punctuation fold to NULL and are dropped.
+CREATE FUNCTION pg_temp.genre_slug(g text) RETURNS text AS $$
+  SELECT CASE
+    WHEN folded <> '' THEN folded
+    WHEN g ~ '[[:alnum:]]' THEN 'genre-' || left(md5(g), 8)
+  END
+  FROM (SELECT trim(both '-' FROM regexp_replace(lower(g), '[^a-z0-9]+', '-', 'g')) AS folded) AS f
+$$ LANGUAGE sql IMMUTABLE;
+
+-- array_length of an empty array is NULL, which a CHECK lets through, so
+-- count the genres with cardinality instead. A movie left with no genres by
+-- the rewrite below then aborts the migration rather than being saved.
+ALTER TABLE movies DROP CONSTRAINT genres_length_check;
+ALTER TABLE movies ADD CONSTRAINT genres_length_check CHECK (cardinality(genres) BETWEEN 1 AND 5);
+
+-- Seed the catalogue from the free-text genres already in use. The first
+-- spelling seen for each slug becomes the display name.
+INSERT INTO genres (slug, name)
+SELECT DISTINCT ON (slug) slug, g
+FROM (
+  SELECT g, pg_temp.genre_slug(g) AS slug
+  FROM movies, unnest(genres) AS g
+) AS s
+WHERE slug IS NOT NULL
+ORDER BY slug, g
+ON CONFLICT DO NOTHING;
+
+INSERT INTO genre_aliases (alias, genre_id)
+SELECT slug, id FROM genres
+ON CONFLICT DO NOTHING;
+
+-- Rewrite each movie's genres to slugs, dropping repeats and keeping order.
+UPDATE movies
+SET genres = ARRAY(
+  SELECT slug FROM (
+    SELECT pg_temp.genre_slug(g) AS slug, min(ord) AS ord
+    FROM unnest(movies.genres) WITH ORDINALITY AS t(g, ord)
+    GROUP BY 1
+  ) AS s
+  WHERE slug IS NOT NULL
+  ORDER BY ord
+)
+WHERE genres IS DISTINCT FROM ARRAY(
+  SELECT pg_temp.genre_slug(g)
+  FROM unnest(movies.genres) AS g
+);
+
+-- Changing the catalogue retags movies across the board, so it needs more
+-- than movies:write. See the README for granting it.
+INSERT INTO permissions (code) VALUES
+('genres:write');
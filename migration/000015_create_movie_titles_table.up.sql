@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS movie_titles (
+  movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  language text NOT NULL,
+  title text NOT NULL,
+  PRIMARY KEY (movie_id, language)
+);
+CREATE INDEX IF NOT EXISTS movie_titles_title_idx ON movie_titles USING GIN (to_tsvector('simple', title));
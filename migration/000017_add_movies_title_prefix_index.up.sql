@@ -0,0 +1,2 @@
+-- Serves the lower(title) LIKE 'prefix%' lookups behind title suggestions.
+CREATE INDEX IF NOT EXISTS movies_title_prefix_idx ON movies (lower(title) text_pattern_ops) WHERE deleted_at IS NULL;
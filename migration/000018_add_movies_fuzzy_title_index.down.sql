@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS movies_title_fuzzy_idx;
+DROP FUNCTION IF EXISTS immutable_unaccent(text);
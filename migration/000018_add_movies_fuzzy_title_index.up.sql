@@ -0,0 +1,10 @@
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+-- unaccent is only STABLE because its dictionary can change, so it cannot be
+-- used in an index directly. Pinning the dictionary makes this wrapper safe
+-- to declare IMMUTABLE.
+CREATE OR REPLACE FUNCTION immutable_unaccent(text) RETURNS text
+LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT
+AS $$ SELECT public.unaccent('public.unaccent', $1) $$;
+
+CREATE INDEX IF NOT EXISTS movies_title_fuzzy_idx ON movies USING GIN (immutable_unaccent(lower(title)) gin_trgm_ops);
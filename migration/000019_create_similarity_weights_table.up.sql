@@ -0,0 +1,11 @@
+-- A single row of weights for GET /v1/movie/:id/similar, kept in the
+-- database so they can be tuned through the API without a deploy.
+CREATE TABLE IF NOT EXISTS similarity_weights (
+  id integer PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+  genres real NOT NULL DEFAULT 0.5 CHECK (genres >= 0),
+  year real NOT NULL DEFAULT 0.2 CHECK (year >= 0),
+  runtime real NOT NULL DEFAULT 0.1 CHECK (runtime >= 0),
+  title real NOT NULL DEFAULT 0.2 CHECK (title >= 0),
+  version integer NOT NULL DEFAULT 1
+);
+INSERT INTO similarity_weights (id) VALUES (1) ON CONFLICT DO NOTHING;
@@ -0,0 +1,28 @@
+-- A relation reads "movie_id is a <kind> of related_movie_id", so a sequel
+-- points back at the film it follows.
+CREATE TABLE IF NOT EXISTS movie_relations (
+  id bigserial PRIMARY KEY,
+  movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  related_movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  kind text NOT NULL CHECK (kind IN ('sequel', 'prequel', 'remake', 'spin-off')),
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  CHECK (movie_id <> related_movie_id),
+  UNIQUE (movie_id, related_movie_id, kind)
+);
+CREATE INDEX IF NOT EXISTS movie_relations_related_movie_id_idx ON movie_relations (related_movie_id);
+
+CREATE TABLE IF NOT EXISTS collections (
+  id bigserial PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  name text NOT NULL,
+  description text NOT NULL DEFAULT '',
+  version integer NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS collection_movies (
+  collection_id bigint NOT NULL REFERENCES collections ON DELETE CASCADE,
+  movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  position integer NOT NULL,
+  PRIMARY KEY (collection_id, movie_id)
+);
+CREATE INDEX IF NOT EXISTS collection_movies_movie_id_idx ON collection_movies (movie_id);
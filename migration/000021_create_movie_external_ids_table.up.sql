@@ -0,0 +1,10 @@
+-- Identifiers for a movie in other catalogues. A value belongs to at most
+-- one movie in its namespace, and a movie has at most one value per
+-- namespace.
+CREATE TABLE IF NOT EXISTS movie_external_ids (
+  movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  namespace text NOT NULL,
+  value text NOT NULL,
+  PRIMARY KEY (namespace, value),
+  UNIQUE (movie_id, namespace)
+);
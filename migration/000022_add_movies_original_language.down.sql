@@ -0,0 +1,2 @@
+ALTER TABLE movie_revisions DROP COLUMN IF EXISTS original_language;
+ALTER TABLE movies DROP COLUMN IF EXISTS original_language;
@@ -0,0 +1,5 @@
+-- The language the movie's own title is in, so it can be chosen alongside
+-- the localized titles in movie_titles. Empty when unknown. Revisions keep
+-- it too, so history, as_of reads and reverts cover it.
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS original_language text NOT NULL DEFAULT '';
+ALTER TABLE movie_revisions ADD COLUMN IF NOT EXISTS original_language text NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS movie_titles_title_fuzzy_idx;
@@ -0,0 +1,3 @@
+-- Lets fuzzy title searches match localized titles through the same
+-- expression movies_title_fuzzy_idx is built on.
+CREATE INDEX IF NOT EXISTS movie_titles_title_fuzzy_idx ON movie_titles USING GIN (immutable_unaccent(lower(title)) gin_trgm_ops);
@@ -0,0 +1,6 @@
+-- Site-wide settings such as the similarity weights change results for
+-- every user, so they get their own permissions rather than riding on
+-- movies:read and movies:write. Nobody holds them until an administrator is
+-- granted them; see the README.
+INSERT INTO permissions (code) VALUES
+('settings:read'), ('settings:write');